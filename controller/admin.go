@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"dekamond-task/controller/dto"
+	accesslist "dekamond-task/package/access_list"
+	"dekamond-task/package/config"
+	"dekamond-task/package/dispatch"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/response"
+)
+
+type AdminController struct {
+	watcher         *config.Watcher
+	deprecatedUsage func() map[string]int64
+	dispatcher      *dispatch.Dispatcher
+	access          *accesslist.List
+	log             *slog.Logger
+}
+
+func NewAdminController(w *config.Watcher, deprecatedUsage func() map[string]int64, d *dispatch.Dispatcher,
+	a *accesslist.List, log *slog.Logger) *AdminController {
+	return &AdminController{watcher: w, deprecatedUsage: deprecatedUsage, dispatcher: d, access: a, log: log}
+}
+
+// ReloadConfigHandler handles POST /admin/config/reload.
+// @Summary Reload configuration
+// @Description Reload the config file now; a bad config is rejected and the current one stays live (requires client certificate).
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} response.Response[any] "Config reloaded"
+// @Failure 403 {object} response.ErrorResponse "Client certificate required"
+// @Failure 422 {object} response.ErrorResponse "Config rejected"
+// @Router /admin/config/reload [post]
+func (ac *AdminController) ReloadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), ac.log)
+	if err := ac.watcher.Reload(); err != nil {
+		log.Error("config reload rejected, keeping previous config", "trigger", "admin", "err", err)
+		response.Fail(w, r, response.NewError(http.StatusUnprocessableEntity, "config_rejected", "%s", err.Error()))
+		return
+	}
+	log.Info("config reloaded", "trigger", "admin")
+	response.Success[any](w, nil, "Config reloaded")
+}
+
+// DeprecatedUsageHandler handles GET /admin/deprecations.
+// @Summary Deprecated route usage
+// @Description Hit counts of deprecated routes since startup, keyed by route pattern (requires client certificate).
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} response.Response[map[string]int64] "Usage per route"
+// @Failure 403 {object} response.ErrorResponse "Client certificate required"
+// @Router /admin/deprecations [get]
+func (ac *AdminController) DeprecatedUsageHandler(w http.ResponseWriter, r *http.Request) {
+	usage := ac.deprecatedUsage()
+	response.Success(w, &usage, "Deprecated route usage fetched successfully")
+}
+
+// DeliveryHistoryHandler handles GET /admin/deliveries.
+// @Summary OTP delivery history
+// @Description Recent OTP deliveries to a phone or email, newest first, with provider delivery reports (requires client certificate).
+// @Tags Admin
+// @Produce json
+// @Param phone query string false "Phone (09XXXXXXXXX)"
+// @Param email query string false "Email, instead of phone"
+// @Success 200 {object} response.Response[[]dto.DeliveryHistoryEntry] "Delivery history"
+// @Failure 400 {object} response.ErrorResponse "Invalid phone or email"
+// @Failure 403 {object} response.ErrorResponse "Client certificate required"
+// @Router /admin/deliveries [get]
+func (ac *AdminController) DeliveryHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	q := dto.DeliveryHistoryQuery{Phone: r.URL.Query().Get("phone"), Email: r.URL.Query().Get("email")}
+	if err := validate(r, &q); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+
+	history := ac.dispatcher.History(identifier(q.Phone, q.Email))
+	out := make([]dto.DeliveryHistoryEntry, 0, len(history))
+	for _, d := range history {
+		e := dto.DeliveryHistoryEntry{
+			ID:                d.ID,
+			Channel:           d.Channel,
+			Status:            d.Status,
+			Provider:          d.Provider,
+			ProviderMessageID: d.ProviderMessageID,
+			Attempts:          d.Attempts,
+			Error:             d.Error,
+			CreatedAt:         d.CreatedAt,
+			UpdatedAt:         d.UpdatedAt,
+		}
+		if !d.ReportedAt.IsZero() {
+			e.ReportedAt = &d.ReportedAt
+		}
+		out = append(out, e)
+	}
+	response.Success(w, &out, "Delivery history fetched successfully")
+}
+
+// ListAccessRulesHandler handles GET /admin/access-rules.
+// @Summary List access rules
+// @Description Live allow and deny rules for OTP requests, oldest first, with their hits since startup (requires client certificate).
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} response.Response[[]dto.AccessRuleResponse] "Access rules"
+// @Failure 403 {object} response.ErrorResponse "Client certificate required"
+// @Router /admin/access-rules [get]
+func (ac *AdminController) ListAccessRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules := ac.access.Rules()
+	out := make([]dto.AccessRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, accessRuleResponse(rule))
+	}
+	response.Success(w, &out, "Access rules fetched successfully")
+}
+
+// AddAccessRuleHandler handles POST /admin/access-rules.
+// @Summary Add an access rule
+// @Description Blocks OTP requests by client IP range, client country, phone prefix or phone number, or allowlists
+// @Description a phone number, optionally until expires_at. Allowlisted numbers skip the bot challenge and rate
+// @Description limit; deny rules win over allow rules (requires client certificate).
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body dto.AccessRuleRequest true "Rule"
+// @Success 200 {object} response.Response[dto.AccessRuleResponse] "Rule added"
+// @Failure 400 {object} response.ErrorResponse "Invalid rule"
+// @Failure 403 {object} response.ErrorResponse "Client certificate required"
+// @Router /admin/access-rules [post]
+func (ac *AdminController) AddAccessRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var req dto.AccessRuleRequest
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+
+	log := logger.FromContext(r.Context(), ac.log)
+	rule, err := ac.access.Add(accesslist.Rule{
+		Kind:      req.Kind,
+		Value:     req.Value,
+		Action:    req.Action,
+		Note:      req.Note,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if errors.Is(err, accesslist.ErrInvalidRule) {
+		response.Fail(w, r, response.NewError(http.StatusBadRequest, "access_rule_invalid", "%s", err.Error()))
+		return
+	}
+	if err != nil {
+		log.Error("access rule not added", "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	log.Info("access rule added", "rule_id", rule.ID, "kind", rule.Kind, "value", rule.Value, "action", rule.Action,
+		"expires_at", rule.ExpiresAt, "note", rule.Note)
+	resp := accessRuleResponse(rule)
+	response.Success(w, &resp, "Access rule added")
+}
+
+// RemoveAccessRuleHandler handles DELETE /admin/access-rules/{id}.
+// @Summary Remove an access rule
+// @Description Deletes an access rule before it expires (requires client certificate).
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} response.Response[any] "Rule removed"
+// @Failure 403 {object} response.ErrorResponse "Client certificate required"
+// @Failure 404 {object} response.ErrorResponse "Rule not found"
+// @Router /admin/access-rules/{id} [delete]
+func (ac *AdminController) RemoveAccessRuleHandler(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), ac.log)
+	id := r.PathValue("id")
+	if err := ac.access.Remove(id); err != nil {
+		if !errors.Is(err, accesslist.ErrRuleNotFound) {
+			log.Error("access rule not removed", "rule_id", id, "err", err)
+		}
+		response.Fail(w, r, err)
+		return
+	}
+	log.Info("access rule removed", "rule_id", id)
+	response.Success[any](w, nil, "Access rule removed")
+}
+
+func accessRuleResponse(rule accesslist.Rule) dto.AccessRuleResponse {
+	return dto.AccessRuleResponse{
+		ID:        rule.ID,
+		Kind:      rule.Kind,
+		Value:     rule.Value,
+		Action:    rule.Action,
+		Note:      rule.Note,
+		CreatedAt: rule.CreatedAt,
+		ExpiresAt: rule.ExpiresAt,
+		Hits:      rule.Hits,
+	}
+}
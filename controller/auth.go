@@ -1,91 +1,446 @@
 package controller
 
 import (
-	"encoding/json"
+	"context"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"dekamond-task/controller/dto"
+	"dekamond-task/middleware"
+	"dekamond-task/model"
+	accesslist "dekamond-task/package/access_list"
+	"dekamond-task/package/captcha"
+	"dekamond-task/package/config"
+	"dekamond-task/package/dispatch"
 	"dekamond-task/package/jwt"
+	"dekamond-task/package/logger"
 	"dekamond-task/package/otp"
+	otptemplate "dekamond-task/package/otp_template"
 	ratelimiter "dekamond-task/package/rate_limiter"
 	"dekamond-task/package/response"
-	"dekamond-task/package/validator"
 	"dekamond-task/service"
 )
 
+// maxDeliveryWait caps long polling of the delivery status, well inside the
+// server's write timeout.
+const maxDeliveryWait = 10 * time.Second
+
 type AuthController struct {
-	otpSvc  *otp.OTPService
-	userSvc *service.UserService
-	limiter *ratelimiter.RateLimiter
+	otpSvc     *otp.OTPService
+	userSvc    *service.UserService
+	mfaSvc     *service.MFAService
+	passkeySvc *service.PasskeyService
+	sessionSvc *service.SessionService
+	riskSvc    *service.RiskService
+	captcha    *captcha.Guard
+	access     *accesslist.List
+	limiter    *ratelimiter.RateLimiter
+	dispatcher *dispatch.Dispatcher
+	log        *slog.Logger
 }
 
-func NewAuthController(o *otp.OTPService, u *service.UserService, l *ratelimiter.RateLimiter) *AuthController {
-	return &AuthController{otpSvc: o, userSvc: u, limiter: l}
+func NewAuthController(o *otp.OTPService, u *service.UserService, m *service.MFAService, p *service.PasskeyService,
+	s *service.SessionService, rs *service.RiskService, g *captcha.Guard, a *accesslist.List, l *ratelimiter.RateLimiter,
+	d *dispatch.Dispatcher, log *slog.Logger) *AuthController {
+	return &AuthController{otpSvc: o, userSvc: u, mfaSvc: m, passkeySvc: p, sessionSvc: s, riskSvc: rs, captcha: g,
+		access: a, limiter: l, dispatcher: d, log: log}
 }
 
 // RequestOTPHandler handles POST /auth/request-otp.
 // @Summary Request OTP
-// @Description Generate OTP for the given phone (Iranian format).
+// @Description Generate OTP for the given phone (Iranian format) or email and queue it for delivery by SMS
+// @Description (default for phones), voice call or email (default for emails, optionally with a magic sign-in link).
+// @Description Poll /auth/deliveries/{id} to learn whether it was sent. Under rate-limit pressure the request
+// @Description is refused with challenge_required until it carries a solved /auth/challenge. Configured test
+// @Description numbers get their fixed code, no message and no delivery ID.
 // @Tags Auth
 // @Accept json
 // @Produce json
-// @Param request body dto.RequestOTPRequest true "Phone number (09XXXXXXXXX)"
-// @Success 200 {object} response.Response[any] "Successful operation"
-// @Failure 400 {object} response.ErrorResponse "Invalid request"
+// @Param request body dto.RequestOTPRequest true "Phone number (09XXXXXXXXX) or email"
+// @Success 200 {object} response.Response[dto.RequestOTPResponse] "Successful operation"
+// @Failure 400 {object} response.ErrorResponse "Invalid request or unavailable channel"
+// @Failure 403 {object} response.ErrorResponse "Challenge required or failed, or blocked by an access rule"
+// @Failure 413 {object} response.ErrorResponse "Body too large"
 // @Failure 429 {object} response.ErrorResponse "Too many requests"
+// @Failure 503 {object} response.ErrorResponse "Delivery queue full or challenge verification unavailable"
 // @Router /auth/request-otp [post]
 func (ac *AuthController) RequestOTPHandler(w http.ResponseWriter, r *http.Request) {
 	var req dto.RequestOTPRequest
-	json.NewDecoder(r.Body).Decode(&req)
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+
+	log := logger.FromContext(r.Context(), ac.log)
 
-	// Validate DTO
-	if err := validator.Validate.Struct(req); err != nil {
-		response.Error(w, http.StatusBadRequest, "request object is not valid")
+	to := identifier(req.Phone, req.Email)
+	channel := req.Channel
+	if channel == "" {
+		channel = otptemplate.ChannelSMS
+		if req.Email != "" {
+			channel = otptemplate.ChannelEmail
+		}
+	}
+	if (channel == otptemplate.ChannelEmail) != model.IsEmail(to) {
+		response.Fail(w, r, response.NewError(http.StatusBadRequest, "channel_mismatch",
+			"channel %s cannot deliver to this identifier", channel))
+		return
+	}
+	if req.MagicLink && channel != otptemplate.ChannelEmail {
+		response.Fail(w, r, otp.ErrMagicLinkUnavailable)
 		return
 	}
+	if !ac.dispatcher.Serves(channel) {
+		response.Fail(w, r, dispatch.ErrNoProvider)
+		return
+	}
+
+	// Test numbers get their fixed code and no message, so the checks against
+	// SMS abuse do not apply to them; deny rules still do
+	test := ac.otpSvc.IsTestNumber(to)
+	if err := ac.admitOTPRequest(r, to, channel, req.ChallengeResponse, test); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	if !test {
+		ac.riskSvc.RecordOTPRequest(to)
+	}
+
+	// Generate and store OTP, then queue it for delivery
+	id, err := ac.otpSvc.GenerateOTP(r.Context(), otp.Request{
+		To:        to,
+		Channel:   channel,
+		App:       req.App,
+		MagicLink: req.MagicLink,
+	})
+	if err != nil {
+		log.Error("OTP generation failed", "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	status := dispatch.StatusQueued
+	if test {
+		status = dispatch.StatusSent
+	}
+	response.Success(w, &dto.RequestOTPResponse{DeliveryID: id, Status: status}, "OTP sent successfully")
+}
+
+// admitOTPRequest applies the access rules, then the bot challenge and rate
+// limit unless to is an allowed or test number; costlier channels have their
+// own rate-limit policy.
+func (ac *AuthController) admitOTPRequest(r *http.Request, to, channel, challengeResponse string, test bool) error {
+	log := logger.FromContext(r.Context(), ac.log)
+	ip := middleware.ClientIP(r)
+	phone := to
+	if model.IsEmail(to) {
+		phone = ""
+	}
+	rule := ac.access.Check(accesslist.Request{Phone: phone, IP: ip})
+	if rule != nil && rule.Action == accesslist.ActionDeny {
+		log.Warn("OTP request denied by access rule", "to", to, "rule_id", rule.ID, "kind", rule.Kind, "value", rule.Value)
+		return accesslist.ErrDenied
+	}
+	if rule != nil {
+		log.Info("OTP request allowed by access rule", "to", to, "rule_id", rule.ID, "kind", rule.Kind, "value", rule.Value)
+		return nil
+	}
+	if test {
+		return nil
+	}
+
+	policy := config.OTPRateLimitPolicy(channel)
+	if ac.captcha.Required(policy, to) {
+		if err := ac.captcha.Verify(r.Context(), challengeResponse, ip); err != nil {
+			log.Warn("OTP request challenge not passed", "to", to, "err", err)
+			return err
+		}
+	}
+	if err := ac.limiter.Allow(policy, to); err != nil {
+		log.Warn("OTP request rate limited", "to", to, "policy", policy)
+		return err
+	}
+	return nil
+}
 
-	// Rate limit check
-	if err := ac.limiter.Allow(req.Phone); err != nil {
-		response.Error(w, http.StatusTooManyRequests, "too many requests")
+// ChallengeHandler handles GET /auth/challenge.
+// @Summary Bot challenge
+// @Description Returns the challenge to solve when request-otp answers challenge_required: a proof-of-work
+// @Description puzzle, or the site key of the hosted CAPTCHA widget to render. Each solution works once.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} response.Response[dto.ChallengeResponse] "Challenge"
+// @Router /auth/challenge [get]
+func (ac *AuthController) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := ac.captcha.Issue()
+	if err != nil {
+		logger.FromContext(r.Context(), ac.log).Error("challenge not issued", "err", err)
+		response.Fail(w, r, err)
 		return
 	}
+	resp := &dto.ChallengeResponse{Provider: c.Provider, SiteKey: c.SiteKey}
+	if p := c.Puzzle; p != nil {
+		resp.Challenge, resp.Difficulty, resp.Algorithm, resp.ExpiresAt = p.Challenge, p.Difficulty, p.Algorithm, &p.ExpiresAt
+	}
+	response.Success(w, resp, "Challenge issued")
+}
 
-	// Generate and store OTP
-	ac.otpSvc.GenerateOTP(req.Phone)
-	response.Success[any](w, nil, "OTP sent successfully")
+// DeliveryStatusHandler handles GET /auth/deliveries/{id}.
+// @Summary OTP delivery status
+// @Description Reports whether a requested OTP reached the provider. With wait (e.g. 5s, at most 10s)
+// @Description the call blocks until delivery succeeds or fails for good.
+// @Tags Auth
+// @Produce json
+// @Param id path string true "Delivery ID from request-otp"
+// @Param wait query string false "Long-poll duration"
+// @Success 200 {object} response.Response[dto.DeliveryResponse] "Delivery status"
+// @Failure 400 {object} response.ErrorResponse "Invalid wait"
+// @Failure 404 {object} response.ErrorResponse "Unknown or expired delivery"
+// @Router /auth/deliveries/{id} [get]
+func (ac *AuthController) DeliveryStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var wait time.Duration
+	if s := r.URL.Query().Get("wait"); s != "" {
+		var err error
+		wait, err = time.ParseDuration(s)
+		if err != nil || wait < 0 || wait > maxDeliveryWait {
+			response.Fail(w, r, response.NewError(http.StatusBadRequest, "invalid_request",
+				"wait must be a duration of at most %s", maxDeliveryWait))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	d, err := ac.dispatcher.Wait(ctx, id)
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &dto.DeliveryResponse{
+		ID:        d.ID,
+		Channel:   d.Channel,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		UpdatedAt: d.UpdatedAt,
+	}, "Delivery status fetched successfully")
 }
 
 // VerifyOTPHandler handles POST /auth/verify.
 // @Summary Verify OTP and login/register
-// @Description Validates OTP, registers user if new, and returns JWT.
+// @Description Validates OTP, registers user if new, and returns JWT. Users with two-factor authentication
+// @Description get mfa_required and a challenge_token to complete at /auth/mfa/verify instead. Sign-ins are
+// @Description scored for risk (new device, country or network, impossible travel, rapid OTP requests): risky
+// @Description ones need the second factor, or a second code sent like the first (second_factor "code") for users
+// @Description without one; users with a passkey get step_up_required. The riskiest are refused.
 // @Tags Auth
 // @Accept json
 // @Produce json
-// @Param request body dto.VerifyOTPRequest true "Phone and OTP"
+// @Param request body dto.VerifyOTPRequest true "Phone or email, and OTP"
 // @Success 200 {object} response.Response[dto.VerifyOTPResponse] "Login successful"
 // @Failure 400 {object} response.ErrorResponse "Invalid input"
+// @Failure 413 {object} response.ErrorResponse "Body too large"
 // @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 403 {object} response.ErrorResponse "Sign-in blocked as suspicious"
 // @Router /auth/verify [post]
 func (ac *AuthController) VerifyOTPHandler(w http.ResponseWriter, r *http.Request) {
 	var req dto.VerifyOTPRequest
-	json.NewDecoder(r.Body).Decode(&req)
-
-	// Validate DTO
-	if err := validator.Validate.Struct(req); err != nil {
-		response.Error(w, http.StatusBadRequest, "request object is not valid")
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
 		return
 	}
 
 	// Validate OTP
-	if err := ac.otpSvc.ValidateOTP(req.Phone, req.OTP); err != nil {
-		response.Error(w, http.StatusUnauthorized, err.Error())
+	to := identifier(req.Phone, req.Email)
+	if err := ac.otpSvc.ValidateOTP(r.Context(), to, req.OTP); err != nil {
+		logger.FromContext(r.Context(), ac.log).Info("OTP verification failed", "to", to, "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	ac.login(w, r, to)
+}
+
+// MagicLinkHandler handles GET /auth/magic.
+// @Summary Sign in with a magic link
+// @Description Completes verification with the single-use link emailed with an OTP, registers the user if new,
+// @Description and returns JWT (or an MFA challenge, like /auth/verify). The link and the code share one lifetime:
+// @Description using either spends both.
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Token from the emailed link"
+// @Success 200 {object} response.Response[dto.VerifyOTPResponse] "Login successful"
+// @Failure 401 {object} response.ErrorResponse "Invalid, expired or used link"
+// @Failure 403 {object} response.ErrorResponse "Sign-in blocked as suspicious"
+// @Router /auth/magic [get]
+func (ac *AuthController) MagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		response.Fail(w, r, response.ErrMissingToken)
+		return
+	}
+	to, nonce, err := jwt.ValidateLinkToken(token)
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	if err := ac.otpSvc.ValidateMagicLink(r.Context(), to, nonce); err != nil {
+		logger.FromContext(r.Context(), ac.log).Info("magic link rejected", "to", to, "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	ac.login(w, r, to)
+}
+
+// VerifyMFAHandler handles POST /auth/mfa/verify.
+// @Summary Complete sign-in with a second factor
+// @Description Checks a code from the authenticator app, or a single-use recovery code, against the
+// @Description challenge token from /auth/verify and returns JWT. Step-up challenges (second_factor "code")
+// @Description take the second code that was sent instead.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body dto.VerifyMFARequest true "Challenge token and code or recovery code"
+// @Success 200 {object} response.Response[dto.VerifyOTPResponse] "Login successful"
+// @Failure 400 {object} response.ErrorResponse "Invalid input"
+// @Failure 401 {object} response.ErrorResponse "Wrong code, or expired challenge"
+// @Failure 413 {object} response.ErrorResponse "Body too large"
+// @Router /auth/mfa/verify [post]
+func (ac *AuthController) VerifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req dto.VerifyMFARequest
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+
+	user, stepUp, err := ac.mfaSvc.CompleteChallenge(r.Context(), req.ChallengeToken, req.Code, req.RecoveryCode)
+	if err != nil {
+		logger.FromContext(r.Context(), ac.log).Info("second factor rejected", "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	ac.issueJWT(w, r, user, stepUp)
+}
+
+// PasskeyOptionsHandler handles POST /auth/passkey/options.
+// @Summary Start passkey sign-in
+// @Description Returns the options to pass to navigator.credentials.get (binary fields in base64url), then send
+// @Description its response to /auth/passkey/verify before the timeout. Any passkey registered here may answer.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} response.Response[webauthn.RequestOptions] "Request options"
+// @Failure 503 {object} response.ErrorResponse "Too many ceremonies in progress"
+// @Router /auth/passkey/options [post]
+func (ac *AuthController) PasskeyOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := ac.passkeySvc.BeginLogin(r.Context())
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &opts, "Sign in with your passkey")
+}
+
+// PasskeyLoginHandler handles POST /auth/passkey/verify.
+// @Summary Sign in with a passkey
+// @Description Verifies the authenticator's assertion and returns JWT. No OTP is sent, and since passkeys
+// @Description require user verification no second factor is asked for either.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body dto.PasskeyLoginRequest true "Authenticator assertion"
+// @Success 200 {object} response.Response[dto.VerifyOTPResponse] "Login successful"
+// @Failure 400 {object} response.ErrorResponse "Invalid input"
+// @Failure 401 {object} response.ErrorResponse "Assertion did not verify, or expired challenge"
+// @Failure 413 {object} response.ErrorResponse "Body too large"
+// @Router /auth/passkey/verify [post]
+func (ac *AuthController) PasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req dto.PasskeyLoginRequest
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
 		return
 	}
 
+	usr, err := ac.passkeySvc.FinishLogin(r.Context(), req.ID, req.ClientDataJSON, req.AuthenticatorData, req.Signature, req.UserHandle)
+	if err != nil {
+		logger.FromContext(r.Context(), ac.log).Info("passkey sign-in failed", "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	ac.issueJWT(w, r, usr.ID(), false)
+}
+
+// login registers the verified user if new and responds with a JWT, or with
+// a challenge when the user has a second factor. Risky sign-ins are refused,
+// or need a second factor: users with a passkey are told to use it, and
+// everyone else is sent a second code.
+func (ac *AuthController) login(w http.ResponseWriter, r *http.Request, to string) {
 	// Register or fetch existing user
-	user := ac.userSvc.RegisterIfNotExists(req.Phone)
+	user := ac.userSvc.RegisterIfNotExists(r.Context(), to)
 
-	// Generate JWT
-	token, _ := jwt.CreateJWT(user.Phone)
+	risk := ac.riskSvc.Assess(r.Context(), service.SignIn{User: user.ID(), UserAgent: r.UserAgent(), IP: middleware.ClientIP(r)})
+	mfa := ac.mfaSvc.Enabled(user.ID())
+	switch {
+	case risk.Action == service.RiskBlock:
+		response.Fail(w, r, service.ErrSignInBlocked)
+		return
+	case risk.Action == service.RiskStepUp && !mfa && len(user.Passkeys) > 0:
+		response.Fail(w, r, service.ErrStepUpPasskey)
+		return
+	case risk.Action == service.RiskStepUp && !mfa:
+		ac.stepUp(w, r, user)
+		return
+	}
+
+	if mfa {
+		challenge, err := ac.mfaSvc.Challenge(r.Context(), user.ID())
+		if err != nil {
+			logger.FromContext(r.Context(), ac.log).Error("MFA challenge failed", "err", err)
+			response.Fail(w, r, err)
+			return
+		}
+		response.Success(w, &dto.VerifyOTPResponse{MFARequired: true, SecondFactor: dto.SecondFactorApp, ChallengeToken: challenge}, "second factor required")
+		return
+	}
+	ac.issueJWT(w, r, user.ID(), false)
+}
+
+// stepUp sends a risky sign-in by a user without a second factor a fresh
+// code over the channel they sign in with, and responds with a challenge to
+// complete with it.
+func (ac *AuthController) stepUp(w http.ResponseWriter, r *http.Request, user model.User) {
+	log := logger.FromContext(r.Context(), ac.log)
+	channel := otptemplate.ChannelSMS
+	if model.IsEmail(user.ID()) {
+		channel = otptemplate.ChannelEmail
+	}
+	challenge, err := ac.mfaSvc.StepUp(r.Context(), user.ID())
+	if err != nil {
+		log.Error("step-up challenge failed", "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	if _, err := ac.otpSvc.GenerateOTP(r.Context(), otp.Request{To: user.ID(), Channel: channel}); err != nil {
+		log.Error("step-up code not sent", "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &dto.VerifyOTPResponse{MFARequired: true, SecondFactor: dto.SecondFactorCode, ChallengeToken: challenge}, "a second code was sent to confirm this sign-in")
+}
+
+// issueJWT opens a session for the signed-in user and responds with its
+// token. The device joins the user's sign-in history; after a step-up the
+// user is told of the sign-in.
+func (ac *AuthController) issueJWT(w http.ResponseWriter, r *http.Request, user string, steppedUp bool) {
+	_, token, err := ac.sessionSvc.Start(r.Context(), user, r.UserAgent(), middleware.ClientIP(r))
+	if err != nil {
+		logger.FromContext(r.Context(), ac.log).Error("session start failed", "err", err)
+		response.Fail(w, r, err)
+		return
+	}
+	var locale string
+	if usr, err := ac.userSvc.GetUser(user); err == nil {
+		locale = usr.Locale
+	}
+	ac.riskSvc.Remember(r.Context(), service.SignIn{User: user, UserAgent: r.UserAgent(), IP: middleware.ClientIP(r), SteppedUp: steppedUp}, locale)
 	response.Success(w, &dto.VerifyOTPResponse{Token: token}, "login successful")
 }
@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+// AccessRuleRequest adds a rule on who may request OTPs. Value is an IP or
+// CIDR, a phone prefix, a phone number or a country code, after kind.
+type AccessRuleRequest struct {
+	Kind      string     `json:"kind" example:"prefix" enums:"cidr,prefix,number,country" validate:"required,oneof=cidr prefix number country"`
+	Value     string     `json:"value" example:"0990" validate:"required,max=64"`
+	Action    string     `json:"action" example:"deny" enums:"allow,deny" validate:"required,oneof=allow deny"`
+	Note      string     `json:"note,omitempty" example:"SMS pumping from this range" validate:"max=256"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2025-08-26T12:00:00Z"` // never expires when omitted
+}
+
+// AccessRuleResponse is an access rule with how many OTP requests it has
+// decided since startup.
+type AccessRuleResponse struct {
+	ID        string     `json:"id" example:"5f0c6b9e2a7d4c18"`
+	Kind      string     `json:"kind" example:"prefix"`
+	Value     string     `json:"value" example:"0990"`
+	Action    string     `json:"action" example:"deny"`
+	Note      string     `json:"note,omitempty" example:"SMS pumping from this range"`
+	CreatedAt time.Time  `json:"created_at" example:"2025-08-25T12:00:00Z"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2025-08-26T12:00:00Z"`
+	Hits      int64      `json:"hits" example:"42"`
+}
@@ -1,14 +1,65 @@
 package dto
 
+import "time"
+
+// RequestOTPRequest names either a phone or an email address.
 type RequestOTPRequest struct {
-	Phone string `json:"phone" example:"09123456789" validate:"required,startswith=09,len=11"`
+	Phone     string `json:"phone,omitempty" example:"09123456789" validate:"required_without=Email,excluded_with=Email,omitempty,startswith=09,len=11"`
+	Email     string `json:"email,omitempty" example:"user@example.com" validate:"required_without=Phone,omitempty,email,max=254"`
+	Channel   string `json:"channel,omitempty" example:"sms" enums:"sms,voice,email" validate:"omitempty,oneof=sms voice email"` // defaults to sms for phones, email for emails
+	App       string `json:"app,omitempty" example:"dekamond" validate:"omitempty,max=32"`                                       // selects the message template
+	MagicLink bool   `json:"magic_link,omitempty" example:"false"`                                                               // email only: also send a sign-in link
+	// Solved /auth/challenge puzzle or hosted CAPTCHA token, needed when a
+	// request is refused with challenge_required
+	ChallengeResponse string `json:"challenge_response,omitempty" validate:"omitempty,max=4096"`
+}
+
+// ChallengeResponse is the bot challenge to solve before requesting an OTP.
+// For provider "pow" find a nonce such that the SHA-256 of challenge + ":" +
+// nonce starts with difficulty zero bits and send challenge + ":" + nonce as
+// challenge_response; for a hosted CAPTCHA render its widget with site_key
+// and send the token it yields.
+type ChallengeResponse struct {
+	Provider   string     `json:"provider" example:"pow" enums:"pow,recaptcha,hcaptcha,turnstile"`
+	SiteKey    string     `json:"site_key,omitempty"`
+	Challenge  string     `json:"challenge,omitempty" example:"3f2a9c1e5b7d40e8a6c2f1d09b8e7a65.1756123320.18.5d41402abc4b2a76b9719d911017c592"`
+	Difficulty int        `json:"difficulty,omitempty" example:"18"`
+	Algorithm  string     `json:"algorithm,omitempty" example:"sha256"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2025-08-25T12:02:00Z"`
+}
+
+type RequestOTPResponse struct {
+	DeliveryID string `json:"delivery_id,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015"` // none for test numbers
+	Status     string `json:"status" example:"queued"`
+}
+
+// DeliveryResponse is the delivery state of a requested OTP.
+type DeliveryResponse struct {
+	ID        string    `json:"id" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Channel   string    `json:"channel" example:"sms"`
+	Status    string    `json:"status" example:"sent" enums:"queued,sending,retrying,sent,failed,delivered,undelivered"`
+	Attempts  int       `json:"attempts" example:"1"`
+	UpdatedAt time.Time `json:"updated_at" example:"2025-08-25T12:00:00Z"`
 }
 
+// VerifyOTPRequest names the phone or email the OTP was sent to.
 type VerifyOTPRequest struct {
-	Phone string `json:"phone" example:"09123456789" validate:"required,startswith=09,len=11"`
-	OTP   string `json:"otp" example:"1234" validate:"required,len=6,numeric"`
+	Phone string `json:"phone,omitempty" example:"09123456789" validate:"required_without=Email,excluded_with=Email,omitempty,startswith=09,len=11"`
+	Email string `json:"email,omitempty" example:"user@example.com" validate:"required_without=Phone,omitempty,email,max=254"`
+	OTP   string `json:"otp" example:"123456" validate:"required,min=4,max=10,numeric"`
 }
 
+// Second factors a challenge is completed with.
+const (
+	SecondFactorApp  = "app"  // code from the authenticator app, or a recovery code
+	SecondFactorCode = "code" // a second code sent like the first, for risky sign-ins
+)
+
+// VerifyOTPResponse carries the JWT, or for users with a second factor or a
+// risky sign-in a challenge token to complete at /auth/mfa/verify.
 type VerifyOTPResponse struct {
-	Token string `json:"token"`
+	Token          string `json:"token,omitempty"`
+	MFARequired    bool   `json:"mfa_required,omitempty" example:"false"`
+	SecondFactor   string `json:"second_factor,omitempty" enums:"app,code"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
 }
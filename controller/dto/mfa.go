@@ -0,0 +1,33 @@
+package dto
+
+// VerifyMFARequest completes a sign-in with a code from the authenticator app
+// or, when the device is lost, a recovery code. Step-up challenges take the
+// code that was sent instead.
+type VerifyMFARequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required,max=1024"`
+	Code           string `json:"code,omitempty" example:"123456" validate:"required_without=RecoveryCode,excluded_with=RecoveryCode,omitempty,numeric,min=4,max=10"`
+	RecoveryCode   string `json:"recovery_code,omitempty" example:"k3x9q-7hvzt" validate:"required_without=Code,omitempty,max=32"`
+}
+
+// TOTPEnrollmentResponse carries the secret to add to an authenticator app;
+// clients show URI as a QR code.
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	URI    string `json:"uri" example:"otpauth://totp/Dekamond:09123456789?algorithm=SHA1&digits=6&issuer=Dekamond&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+}
+
+// TOTPCodeRequest proves possession of the authenticator app.
+type TOTPCodeRequest struct {
+	Code string `json:"code" example:"123456" validate:"required,numeric,len=6"`
+}
+
+// DisableTOTPRequest turns the second factor off with an app or recovery code.
+type DisableTOTPRequest struct {
+	Code         string `json:"code,omitempty" example:"123456" validate:"required_without=RecoveryCode,excluded_with=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code,omitempty" validate:"required_without=Code,omitempty,max=32"`
+}
+
+// RecoveryCodesResponse lists single-use recovery codes; they are shown once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k3x9q-7hvzt,m2w8r-p4cja"`
+}
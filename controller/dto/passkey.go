@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"time"
+
+	"dekamond-task/package/webauthn"
+)
+
+// RegisterPasskeyRequest is the response of navigator.credentials.create,
+// with binary fields in base64url.
+type RegisterPasskeyRequest struct {
+	Name              string         `json:"name" example:"Work laptop" validate:"omitempty,max=64"`
+	ClientDataJSON    webauthn.Bytes `json:"client_data_json" swaggertype:"string" validate:"required,max=2048"`
+	AttestationObject webauthn.Bytes `json:"attestation_object" swaggertype:"string" validate:"required,max=8192"`
+}
+
+// PasskeyLoginRequest is the response of navigator.credentials.get, with
+// binary fields in base64url.
+type PasskeyLoginRequest struct {
+	ID                webauthn.Bytes `json:"id" swaggertype:"string" validate:"required,max=1023"`
+	ClientDataJSON    webauthn.Bytes `json:"client_data_json" swaggertype:"string" validate:"required,max=2048"`
+	AuthenticatorData webauthn.Bytes `json:"authenticator_data" swaggertype:"string" validate:"required,max=2048"`
+	Signature         webauthn.Bytes `json:"signature" swaggertype:"string" validate:"required,max=1024"`
+	UserHandle        webauthn.Bytes `json:"user_handle,omitempty" swaggertype:"string" validate:"omitempty,max=64"`
+}
+
+type PasskeyResponse struct {
+	ID         string    `json:"id" example:"q8sV0Xk2Q3mN1yB7cE4rTg"`
+	Name       string    `json:"name" example:"Work laptop"`
+	CreatedAt  time.Time `json:"created_at" example:"2025-08-25T12:00:00Z"`
+	LastUsedAt time.Time `json:"last_used_at" example:"2025-08-25T12:00:00Z"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+import "time"
+
+type SessionResponse struct {
+	ID         string    `json:"id" example:"5f0c8e2b9a7d4c1e8b3a6d2f1e0c9b8a"`
+	Device     string    `json:"device" example:"Chrome on Windows"`
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	CreatedAt  time.Time `json:"created_at" example:"2025-08-25T12:00:00Z"`
+	LastSeenAt time.Time `json:"last_seen_at" example:"2025-08-25T12:30:00Z"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2025-08-26T12:00:00Z"`
+	Current    bool      `json:"current" example:"true"` // the session of the token making the request
+}
@@ -3,6 +3,8 @@ package dto
 import "time"
 
 type UserResponse struct {
-	Phone        string    `json:"phone" example:"09123456789"`
+	Phone        string    `json:"phone,omitempty" example:"09123456789"`
+	Email        string    `json:"email,omitempty" example:"user@example.com"`
 	RegisteredAt time.Time `json:"registered_at" example:"2025-08-25T12:00:00Z"`
+	Locale       string    `json:"locale" example:"fa"`
 }
@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// DeliveryReportRequest is a provider's report on a message it accepted.
+// Any status other than delivered means the handset never got it.
+type DeliveryReportRequest struct {
+	MessageID string `json:"message_id" example:"primary-219e08" validate:"required,max=128"`
+	Status    string `json:"status" example:"delivered" validate:"required,oneof=delivered undelivered failed expired rejected"`
+	Error     string `json:"error,omitempty" example:"handset unreachable" validate:"max=256"`
+}
+
+type DeliveryHistoryQuery struct {
+	Phone string `json:"phone" validate:"required_without=Email,excluded_with=Email,omitempty,startswith=09,len=11"`
+	Email string `json:"email" validate:"required_without=Phone,omitempty,email,max=254"`
+}
+
+// DeliveryHistoryEntry is one OTP delivery as support staff see it.
+type DeliveryHistoryEntry struct {
+	ID                string     `json:"id" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Channel           string     `json:"channel" example:"sms"`
+	Status            string     `json:"status" example:"undelivered"`
+	Provider          string     `json:"provider,omitempty" example:"primary"`
+	ProviderMessageID string     `json:"provider_message_id,omitempty" example:"primary-219e08"`
+	Attempts          int        `json:"attempts" example:"1"`
+	Error             string     `json:"error,omitempty" example:"handset unreachable"`
+	CreatedAt         time.Time  `json:"created_at" example:"2025-08-25T12:00:00Z"`
+	UpdatedAt         time.Time  `json:"updated_at" example:"2025-08-25T12:00:05Z"`
+	ReportedAt        *time.Time `json:"reported_at,omitempty" example:"2025-08-25T12:00:05Z"`
+}
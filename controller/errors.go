@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+
+	accesslist "dekamond-task/package/access_list"
+	"dekamond-task/package/captcha"
+	"dekamond-task/package/dispatch"
+	"dekamond-task/package/jwt"
+	"dekamond-task/package/otp"
+	ratelimiter "dekamond-task/package/rate_limiter"
+	"dekamond-task/package/response"
+	"dekamond-task/package/webauthn"
+	"dekamond-task/service"
+)
+
+// domainErrors maps the sentinel errors of the domain packages to HTTP. The
+// token and session errors raised in middleware are here too, since every
+// binary serving these routes links this package. Codes are part of the API
+// contract: add new ones, never rename.
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{otp.ErrExpired, http.StatusUnauthorized, "otp_expired"},
+	{otp.ErrInvalid, http.StatusUnauthorized, "otp_invalid"},
+	{otp.ErrLocked, http.StatusUnauthorized, "otp_locked"},
+	{otp.ErrMagicLinkUnavailable, http.StatusBadRequest, "magic_link_unavailable"},
+	{jwt.ErrTokenExpired, http.StatusUnauthorized, "token_expired"},
+	{jwt.ErrInvalidToken, http.StatusUnauthorized, "token_invalid"},
+	{ratelimiter.ErrLimitExceeded, http.StatusTooManyRequests, "rate_limited"},
+	{accesslist.ErrDenied, http.StatusForbidden, "request_blocked"},
+	{accesslist.ErrRuleNotFound, http.StatusNotFound, "access_rule_not_found"},
+	{captcha.ErrRequired, http.StatusForbidden, "challenge_required"},
+	{captcha.ErrFailed, http.StatusForbidden, "challenge_failed"},
+	{captcha.ErrUnavailable, http.StatusServiceUnavailable, "challenge_unavailable"},
+	{service.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{service.ErrSessionEnded, http.StatusUnauthorized, "session_ended"},
+	{service.ErrSessionNotFound, http.StatusNotFound, "session_not_found"},
+	{service.ErrSignInBlocked, http.StatusForbidden, "sign_in_blocked"},
+	{service.ErrStepUpPasskey, http.StatusUnauthorized, "step_up_required"},
+	{service.ErrMFANotEnabled, http.StatusBadRequest, "mfa_not_enabled"},
+	{service.ErrMFAAlreadyEnabled, http.StatusConflict, "mfa_already_enabled"},
+	{service.ErrMFANoEnrollment, http.StatusNotFound, "mfa_enrollment_not_found"},
+	{service.ErrMFAInvalidCode, http.StatusUnauthorized, "mfa_invalid"},
+	{service.ErrMFAChallengeExpired, http.StatusUnauthorized, "mfa_challenge_expired"},
+	{service.ErrMFALocked, http.StatusUnauthorized, "mfa_locked"},
+	{webauthn.ErrInvalid, http.StatusUnauthorized, "passkey_invalid"},
+	{webauthn.ErrUnsupported, http.StatusBadRequest, "passkey_unsupported"},
+	{service.ErrPasskeyChallenge, http.StatusUnauthorized, "passkey_challenge_expired"},
+	{service.ErrPasskeyNotFound, http.StatusNotFound, "passkey_not_found"},
+	{service.ErrPasskeyExists, http.StatusConflict, "passkey_exists"},
+	{service.ErrPasskeyUnavailable, http.StatusServiceUnavailable, "passkey_unavailable"},
+	{dispatch.ErrQueueFull, http.StatusServiceUnavailable, "delivery_unavailable"},
+	{dispatch.ErrDeliveryNotFound, http.StatusNotFound, "delivery_not_found"},
+	{dispatch.ErrNoProvider, http.StatusBadRequest, "channel_unavailable"},
+}
+
+func init() {
+	for _, m := range domainErrors {
+		response.RegisterError(m.err, m.status, m.code)
+	}
+}
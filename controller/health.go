@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"net/http"
+
+	"dekamond-task/package/health"
+	"dekamond-task/package/response"
+)
+
+type HealthController struct {
+	health *health.Health
+}
+
+func NewHealthController(h *health.Health) *HealthController {
+	return &HealthController{health: h}
+}
+
+// LivenessHandler handles GET /healthz.
+// @Summary Liveness probe
+// @Description Reports that the process is up; it checks no dependencies.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} response.Response[any] "Alive"
+// @Router /healthz [get]
+func (hc *HealthController) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	response.Success[any](w, nil, "alive")
+}
+
+// ReadinessHandler handles GET /readyz.
+// @Summary Readiness probe
+// @Description Runs every dependency check and reports per-check status and latency; 503 while any check fails or the server is shutting down.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} response.Response[health.Report] "Ready"
+// @Failure 503 {object} response.Response[health.Report] "Not ready"
+// @Router /readyz [get]
+func (hc *HealthController) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := hc.health.Check(r.Context())
+	if report.Status != health.StatusOK {
+		response.JSON(w, http.StatusServiceUnavailable, false, &report, "not ready")
+		return
+	}
+	response.Success(w, &report, "ready")
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"net/http"
+
+	"dekamond-task/controller/dto"
+	"dekamond-task/middleware"
+	"dekamond-task/package/response"
+	"dekamond-task/service"
+)
+
+// MFAController manages the signed-in user's authenticator app.
+type MFAController struct {
+	mfaSvc *service.MFAService
+}
+
+func NewMFAController(m *service.MFAService) *MFAController {
+	return &MFAController{mfaSvc: m}
+}
+
+// EnrollTOTPHandler handles POST /mfa/totp.
+// @Summary Start authenticator app enrollment
+// @Description Creates a TOTP secret for the signed-in user. Show uri as a QR code (or the secret for manual entry),
+// @Description then confirm with a first code. Starting again replaces an unconfirmed secret.
+// @Tags MFA
+// @Produce json
+// @Success 200 {object} response.Response[dto.TOTPEnrollmentResponse] "Secret and provisioning URI"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 409 {object} response.ErrorResponse "Already enabled"
+// @Router /mfa/totp [post]
+// @Security BearerAuth
+func (mc *MFAController) EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	secret, uri, err := mc.mfaSvc.Enroll(r.Context(), middleware.Principal(r.Context()))
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &dto.TOTPEnrollmentResponse{Secret: secret, URI: uri}, "Scan the code with your authenticator app")
+}
+
+// ConfirmTOTPHandler handles POST /mfa/totp/confirm.
+// @Summary Confirm authenticator app enrollment
+// @Description Turns on two-factor authentication once a code from the app checks out, and returns single-use
+// @Description recovery codes. They are shown only this once.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param request body dto.TOTPCodeRequest true "Code from the app"
+// @Success 200 {object} response.Response[dto.RecoveryCodesResponse] "Recovery codes"
+// @Failure 400 {object} response.ErrorResponse "Invalid input"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized or wrong code"
+// @Failure 404 {object} response.ErrorResponse "No pending enrollment"
+// @Failure 409 {object} response.ErrorResponse "Already enabled"
+// @Router /mfa/totp/confirm [post]
+// @Security BearerAuth
+func (mc *MFAController) ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req dto.TOTPCodeRequest
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	codes, err := mc.mfaSvc.Confirm(r.Context(), middleware.Principal(r.Context()), req.Code)
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &dto.RecoveryCodesResponse{RecoveryCodes: codes}, "Two-factor authentication enabled")
+}
+
+// RecoveryCodesHandler handles POST /mfa/recovery-codes.
+// @Summary Regenerate recovery codes
+// @Description Replaces all recovery codes after checking a code from the app.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param request body dto.TOTPCodeRequest true "Code from the app"
+// @Success 200 {object} response.Response[dto.RecoveryCodesResponse] "Recovery codes"
+// @Failure 400 {object} response.ErrorResponse "Invalid input or not enabled"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized or wrong code"
+// @Router /mfa/recovery-codes [post]
+// @Security BearerAuth
+func (mc *MFAController) RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	var req dto.TOTPCodeRequest
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	codes, err := mc.mfaSvc.RegenerateRecoveryCodes(r.Context(), middleware.Principal(r.Context()), req.Code)
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &dto.RecoveryCodesResponse{RecoveryCodes: codes}, "Recovery codes regenerated")
+}
+
+// DisableTOTPHandler handles POST /mfa/totp/disable.
+// @Summary Turn off two-factor authentication
+// @Description Removes the authenticator app after checking a code from it or a recovery code.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Param request body dto.DisableTOTPRequest true "Code or recovery code"
+// @Success 200 {object} response.Response[any] "Disabled"
+// @Failure 400 {object} response.ErrorResponse "Invalid input or not enabled"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized or wrong code"
+// @Router /mfa/totp/disable [post]
+// @Security BearerAuth
+func (mc *MFAController) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req dto.DisableTOTPRequest
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	if err := mc.mfaSvc.Disable(r.Context(), middleware.Principal(r.Context()), req.Code, req.RecoveryCode); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success[any](w, nil, "Two-factor authentication disabled")
+}
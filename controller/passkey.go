@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"net/http"
+
+	"dekamond-task/controller/dto"
+	"dekamond-task/middleware"
+	"dekamond-task/package/response"
+	"dekamond-task/service"
+)
+
+// PasskeyController manages the signed-in user's passkeys.
+type PasskeyController struct {
+	passkeySvc *service.PasskeyService
+	userSvc    *service.UserService
+}
+
+func NewPasskeyController(p *service.PasskeyService, u *service.UserService) *PasskeyController {
+	return &PasskeyController{passkeySvc: p, userSvc: u}
+}
+
+// RegistrationOptionsHandler handles POST /passkeys/register/options.
+// @Summary Start passkey registration
+// @Description Returns the options to pass to navigator.credentials.create (binary fields in base64url),
+// @Description then send its response to POST /passkeys before the timeout.
+// @Tags Passkeys
+// @Produce json
+// @Success 200 {object} response.Response[webauthn.CreationOptions] "Creation options"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 503 {object} response.ErrorResponse "Too many ceremonies in progress"
+// @Router /passkeys/register/options [post]
+// @Security BearerAuth
+func (pc *PasskeyController) RegistrationOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := pc.passkeySvc.BeginRegistration(r.Context(), middleware.Principal(r.Context()))
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &opts, "Create the passkey on your device")
+}
+
+// RegisterHandler handles POST /passkeys.
+// @Summary Register a passkey
+// @Description Verifies the authenticator's response to the creation options and stores the passkey.
+// @Description Only "none" and self or "packed" attestation are accepted; user verification is required.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Param request body dto.RegisterPasskeyRequest true "Authenticator response"
+// @Success 200 {object} response.Response[dto.PasskeyResponse] "Registered passkey"
+// @Failure 400 {object} response.ErrorResponse "Invalid input or unsupported passkey"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized, or the response did not verify"
+// @Failure 409 {object} response.ErrorResponse "Passkey already registered"
+// @Router /passkeys [post]
+// @Security BearerAuth
+func (pc *PasskeyController) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var req dto.RegisterPasskeyRequest
+	if err := decodeAndValidate(w, r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	name := req.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	pk, err := pc.passkeySvc.FinishRegistration(r.Context(), middleware.Principal(r.Context()), name, req.ClientDataJSON, req.AttestationObject)
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success(w, &dto.PasskeyResponse{ID: pk.ID, Name: pk.Name, CreatedAt: pk.CreatedAt, LastUsedAt: pk.LastUsedAt}, "Passkey registered")
+}
+
+// ListHandler handles GET /passkeys.
+// @Summary List passkeys
+// @Description Lists the signed-in user's passkeys.
+// @Tags Passkeys
+// @Produce json
+// @Success 200 {object} response.Response[[]dto.PasskeyResponse] "Passkeys"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "User not found"
+// @Router /passkeys [get]
+// @Security BearerAuth
+func (pc *PasskeyController) ListHandler(w http.ResponseWriter, r *http.Request) {
+	usr, err := pc.userSvc.GetUser(middleware.Principal(r.Context()))
+	if err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	out := make([]dto.PasskeyResponse, 0, len(usr.Passkeys))
+	for _, pk := range usr.Passkeys {
+		out = append(out, dto.PasskeyResponse{ID: pk.ID, Name: pk.Name, CreatedAt: pk.CreatedAt, LastUsedAt: pk.LastUsedAt})
+	}
+	response.Success(w, &out, "Passkeys fetched successfully")
+}
+
+// RemoveHandler handles DELETE /passkeys/{id}.
+// @Summary Remove a passkey
+// @Description Deletes a passkey of the signed-in user; it can no longer sign in.
+// @Tags Passkeys
+// @Produce json
+// @Param id path string true "Passkey ID"
+// @Success 200 {object} response.Response[any] "Removed"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "Passkey not found"
+// @Router /passkeys/{id} [delete]
+// @Security BearerAuth
+func (pc *PasskeyController) RemoveHandler(w http.ResponseWriter, r *http.Request) {
+	if err := pc.userSvc.RemovePasskey(middleware.Principal(r.Context()), r.PathValue("id")); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success[any](w, nil, "Passkey removed")
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"dekamond-task/package/i18n"
+	"dekamond-task/package/response"
+	"dekamond-task/package/validator"
+)
+
+// maxBodyBytes caps JSON request bodies; auth payloads are tiny.
+const maxBodyBytes = 16 << 10
+
+// decodeAndValidate reads a single JSON object into dst, rejecting unknown
+// fields and oversized bodies, then validates it. Errors are APIErrors ready
+// for response.Fail.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return response.NewError(http.StatusBadRequest, "malformed_json", "body must contain a single JSON object")
+	}
+	return validate(r, dst)
+}
+
+// validate checks dst's validate tags, reporting failures per field in the
+// request's locale.
+func validate(r *http.Request, dst any) error {
+	if err := validator.Validate.Struct(dst); err != nil {
+		apiErr := *response.ErrInvalidRequest
+		apiErr.Errors = validator.FieldErrors(err, i18n.FromContext(r.Context()))
+		return &apiErr
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var (
+		tooLarge  *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return response.NewError(http.StatusRequestEntityTooLarge, "body_too_large",
+			"body must not be larger than %d bytes", tooLarge.Limit)
+	case errors.Is(err, io.EOF):
+		return response.NewError(http.StatusBadRequest, "malformed_json", "body must not be empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return response.NewError(http.StatusBadRequest, "malformed_json", "body contains malformed JSON")
+	case errors.As(err, &typeErr):
+		return response.NewError(http.StatusBadRequest, "malformed_json",
+			"field %s must be a %s", typeErr.Field, typeErr.Type.String())
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for DisallowUnknownFields
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return response.NewError(http.StatusBadRequest, "unknown_field", "unknown field %s", field)
+	default:
+		return response.NewError(http.StatusBadRequest, "malformed_json", "body could not be decoded")
+	}
+}
+
+// identifier returns whichever of phone or email was given; emails are
+// case-insensitive and stored lowercased.
+func identifier(phone, email string) string {
+	if email != "" {
+		return strings.ToLower(email)
+	}
+	return phone
+}
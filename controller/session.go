@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+
+	"dekamond-task/controller/dto"
+	"dekamond-task/middleware"
+	"dekamond-task/package/response"
+	"dekamond-task/service"
+)
+
+// SessionController lets the signed-in user review and end their sign-ins.
+type SessionController struct {
+	sessionSvc *service.SessionService
+}
+
+func NewSessionController(s *service.SessionService) *SessionController {
+	return &SessionController{sessionSvc: s}
+}
+
+// ListHandler handles GET /sessions.
+// @Summary List sessions
+// @Description Lists the devices the signed-in user is signed in on, most recently seen first.
+// @Tags Sessions
+// @Produce json
+// @Success 200 {object} response.Response[[]dto.SessionResponse] "Sessions"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Router /sessions [get]
+// @Security BearerAuth
+func (sc *SessionController) ListHandler(w http.ResponseWriter, r *http.Request) {
+	current := middleware.SessionID(r.Context())
+	sessions := sc.sessionSvc.List(middleware.Principal(r.Context()))
+	out := make([]dto.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, dto.SessionResponse{
+			ID:         s.ID,
+			Device:     s.Device,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.ID == current,
+		})
+	}
+	response.Success(w, &out, "Sessions fetched successfully")
+}
+
+// TerminateHandler handles DELETE /sessions/{id}.
+// @Summary Sign out a session
+// @Description Ends a session of the signed-in user; its tokens are rejected from then on. Ending the
+// @Description current session signs out this device.
+// @Tags Sessions
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} response.Response[any] "Signed out"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "Session not found"
+// @Router /sessions/{id} [delete]
+// @Security BearerAuth
+func (sc *SessionController) TerminateHandler(w http.ResponseWriter, r *http.Request) {
+	if err := sc.sessionSvc.Terminate(r.Context(), middleware.Principal(r.Context()), r.PathValue("id")); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+	response.Success[any](w, nil, "Session signed out")
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"dekamond-task/controller/dto"
 	"dekamond-task/package/response"
@@ -25,7 +26,7 @@ func NewUserController(u *service.UserService) *UserController {
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param size query int false "Page size" default(10)
-// @Param search query string false "Search by phone substring"
+// @Param search query string false "Search by phone or email substring"
 // @Success 200 {object} response.PaginatedResponse[dto.UserResponse] "Paginated list of users"
 // @Failure 400 {object} response.ErrorResponse "Invalid query"
 // @Failure 401 {object} response.ErrorResponse "Unauthorized"
@@ -50,7 +51,9 @@ func (uc *UserController) ListUsersHandler(w http.ResponseWriter, r *http.Reques
 	for _, u := range users {
 		out = append(out, dto.UserResponse{
 			Phone:        u.Phone,
+			Email:        u.Email,
 			RegisteredAt: u.RegisteredAt,
+			Locale:       u.Locale,
 		})
 	}
 
@@ -59,27 +62,28 @@ func (uc *UserController) ListUsersHandler(w http.ResponseWriter, r *http.Reques
 
 // GetUserHandler handles GET /users/{phone}.
 // @Summary Get user by phone
-// @Description Retrieve a single user by phone number.
+// @Description Retrieve a single user by phone number or email.
 // @Tags Users
 // @Accept json
 // @Produce json
-// @Param phone path string true "User phone"
+// @Param phone path string true "User phone or email"
 // @Success 200 {object} response.Response[dto.UserResponse] "User details"
 // @Failure 404 {object} response.ErrorResponse "User not found"
 // @Failure 401 {object} response.ErrorResponse "Unauthorized"
 // @Router /users/{phone} [get]
 // @Security BearerAuth
 func (uc *UserController) GetUserHandler(w http.ResponseWriter, r *http.Request) {
-	phone := r.URL.Path[len("/users/"):]
-	u, ok := uc.userSvc.GetUser(phone)
-	if !ok {
-		response.Error(w, http.StatusNotFound, "User not found")
+	u, err := uc.userSvc.GetUser(strings.ToLower(r.PathValue("phone")))
+	if err != nil {
+		response.Fail(w, r, err)
 		return
 	}
 
 	payload := dto.UserResponse{
 		Phone:        u.Phone,
+		Email:        u.Email,
 		RegisteredAt: u.RegisteredAt,
+		Locale:       u.Locale,
 	}
 	response.Success(w, &payload, "User fetched successfully")
 }
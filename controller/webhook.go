@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dekamond-task/controller/dto"
+	"dekamond-task/package/dispatch"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/response"
+)
+
+// Delivery report signatures: hex HMAC-SHA256 over "<id>.<timestamp>.<body>"
+// with the provider's webhook secret, where id is unique per report. Reports
+// older than signatureTolerance are refused, and an ID seen within it is not
+// applied again, so captured requests can't be replayed.
+const (
+	idHeader           = "X-Webhook-ID"
+	signatureHeader    = "X-Webhook-Signature"
+	timestampHeader    = "X-Webhook-Timestamp"
+	signatureTolerance = 5 * time.Minute
+)
+
+type WebhookController struct {
+	dispatcher *dispatch.Dispatcher
+	secrets    map[string][]byte // provider name -> webhook secret
+	log        *slog.Logger
+
+	mu   sync.Mutex
+	seen map[string]time.Time // provider/report ID -> when it was recorded
+}
+
+func NewWebhookController(d *dispatch.Dispatcher, secrets map[string][]byte, log *slog.Logger) *WebhookController {
+	return &WebhookController{dispatcher: d, secrets: secrets, log: log, seen: make(map[string]time.Time)}
+}
+
+// DeliveryReportHandler handles POST /webhooks/delivery/{provider}.
+// @Summary Provider delivery report
+// @Description Records whether an SMS reached the handset. Signed with the provider's webhook secret. A report
+// @Description whose ID was already recorded is acknowledged without being applied again.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name from the dispatch config"
+// @Param X-Webhook-ID header string true "Unique report ID, reused on retries"
+// @Param X-Webhook-Timestamp header string true "Unix seconds"
+// @Param X-Webhook-Signature header string true "sha256=<hex HMAC-SHA256 of id.timestamp.body>"
+// @Param request body dto.DeliveryReportRequest true "Delivery report"
+// @Success 200 {object} response.Response[any] "Report recorded"
+// @Failure 400 {object} response.ErrorResponse "Invalid report"
+// @Failure 401 {object} response.ErrorResponse "Bad signature"
+// @Failure 404 {object} response.ErrorResponse "Unknown provider or message"
+// @Router /webhooks/delivery/{provider} [post]
+func (wc *WebhookController) DeliveryReportHandler(w http.ResponseWriter, r *http.Request) {
+	provider := r.PathValue("provider")
+	secret, ok := wc.secrets[provider]
+	if !ok {
+		response.Fail(w, r, response.ErrNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		response.Fail(w, r, decodeError(err))
+		return
+	}
+	log := logger.FromContext(r.Context(), wc.log).With("provider", provider)
+	id := r.Header.Get(idHeader)
+	if !validReportID(id) || !validSignature(secret, id, r.Header.Get(timestampHeader), r.Header.Get(signatureHeader), body) {
+		log.Warn("delivery report with bad signature")
+		response.Fail(w, r, response.ErrInvalidSignature)
+		return
+	}
+	log = log.With("report_id", id)
+
+	// Providers add fields over time, so unknown ones are ignored here
+	var req dto.DeliveryReportRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		response.Fail(w, r, decodeError(err))
+		return
+	}
+	if err := validate(r, &req); err != nil {
+		response.Fail(w, r, err)
+		return
+	}
+
+	key := provider + "/" + id
+	if !wc.claim(key) {
+		log.Info("duplicate delivery report ignored", "message_id", req.MessageID)
+		response.Success[any](w, nil, "Report already recorded")
+		return
+	}
+	delivered := req.Status == "delivered"
+	if err := wc.dispatcher.Report(provider, req.MessageID, delivered, req.Error); err != nil {
+		wc.release(key)
+		log.Info("delivery report for unknown message", "message_id", req.MessageID)
+		response.Fail(w, r, err)
+		return
+	}
+	log.Info("delivery report recorded", "message_id", req.MessageID, "status", req.Status)
+	response.Success[any](w, nil, "Report recorded")
+}
+
+// claim records a report ID, reporting false if it was already recorded
+// within the signature tolerance; older IDs are forgotten, as their
+// signatures no longer verify.
+func (wc *WebhookController) claim(key string) bool {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	now := time.Now()
+	for k, at := range wc.seen {
+		if now.Sub(at) > 2*signatureTolerance {
+			delete(wc.seen, k)
+		}
+	}
+	if _, dup := wc.seen[key]; dup {
+		return false
+	}
+	wc.seen[key] = now
+	return true
+}
+
+// release forgets a claimed report ID whose report was not applied, so the
+// provider's retry is.
+func (wc *WebhookController) release(key string) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	delete(wc.seen, key)
+}
+
+// validReportID accepts up to 128 printable ASCII characters other than
+// spaces and dots, which separate the signed fields.
+func validReportID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' || id[i] == '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func validSignature(secret []byte, id, timestamp, signature string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
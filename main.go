@@ -1,50 +1,566 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"dekamond-task/controller"
 	"dekamond-task/middleware"
+	accesslist "dekamond-task/package/access_list"
+	"dekamond-task/package/captcha"
+	certreloader "dekamond-task/package/cert_reloader"
+	"dekamond-task/package/config"
+	"dekamond-task/package/dispatch"
+	"dekamond-task/package/geoip"
+	"dekamond-task/package/health"
+	"dekamond-task/package/jwt"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/metrics"
 	"dekamond-task/package/otp"
+	otptemplate "dekamond-task/package/otp_template"
 	ratelimiter "dekamond-task/package/rate_limiter"
+	"dekamond-task/package/router"
+	"dekamond-task/package/tracing"
+	"dekamond-task/package/webauthn"
 	"dekamond-task/service"
 
-	_ "dekamond-task/docs"
+	v1docs "dekamond-task/docs/v1"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger" // Swagger UI handler
 )
 
 // @title           Dekamond Task API
 // @version         1.0
 // @description     OTP-based authentication and user management API.
-// @BasePath        /
+// @BasePath        /v1
 // @schemes         http
 // @host            localhost:8080
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
 func main() {
+	configPath := flag.String("config", "config.json", "path to the JSON config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal(slog.Default(), "error loading config", err)
+	}
+
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(mustParseLevel(cfg.Log.Level))
+	log := logger.New(os.Stdout, logger.Options{Format: cfg.Log.Format, Level: logLevel, Redact: cfg.Log.Redact})
+	slog.SetDefault(log)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Enabled:     cfg.Tracing.Enabled,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal(log, "error setting up tracing", err)
+	}
+
 	// Initialize in-memory stores and services
-	userSvc := service.NewUserService()
-	otpSvc := otp.NewOTPService()
-	limiter := ratelimiter.NewRateLimiter()
+	userSvc := service.NewUserService(log)
+	passkeySvc := service.NewPasskeyService(passkeyPolicy(cfg), userSvc, log)
+	sessionSvc := service.NewSessionService(sessionPolicy(cfg), log)
+	templates, err := cfg.OTP.MessageTemplates()
+	if err != nil {
+		fatal(log, "error compiling OTP templates", err)
+	}
+	otpMessages := otptemplate.NewRenderer(templates)
+	dispatcher := newDispatcher(cfg.Dispatch, log)
+	otpSvc := otp.NewOTPService(otpPolicy(cfg, cfg.Server.Mode), otpMessages, dispatcher, log)
+	mfaSvc := service.NewMFAService(mfaPolicy(cfg), otpSvc, log)
+	if len(cfg.OTP.TestNumbers) > 0 {
+		log.Warn("OTP test numbers enabled", "mode", cfg.Server.Mode, "count", len(cfg.OTP.TestNumbers))
+	}
+	limiter := ratelimiter.NewRateLimiter(rateLimitPolicies(cfg))
+	geoIPPath := cfg.Risk.GeoIPDB
+	geoIP, err := loadGeoIP(geoIPPath)
+	if err != nil {
+		fatal(log, "error loading GeoIP database", err)
+	}
+	riskSvc := service.NewRiskService(riskPolicy(cfg, geoIP), dispatcher, log)
+	pow, err := captcha.NewProofOfWork(cfg.Captcha.PoWDifficulty, time.Duration(cfg.Captcha.PoWTTL))
+	if err != nil {
+		fatal(log, "error setting up proof of work", err)
+	}
+	guard := captcha.NewGuard(captchaPolicy(cfg), captchaVerifier(cfg, pow), limiter)
+	access, err := accesslist.Open(cfg.Access.RulesFile, geoIP, log)
+	if err != nil {
+		fatal(log, "error loading access rules", err)
+	}
+	metrics.RegisterStateGauges(otpSvc.Len, limiter.Len, dispatcher.Len, sessionSvc.Len)
+
+	// Dependency checks behind /readyz
+	checks := health.New(2 * time.Second)
+	checks.Register("user_store", userSvc.Ping)
+	checks.Register("otp_store", otpSvc.Ping)
+	checks.Register("mfa_store", mfaSvc.Ping)
+	checks.Register("passkey_store", passkeySvc.Ping)
+	checks.Register("session_store", sessionSvc.Ping)
+	checks.Register("risk_store", riskSvc.Ping)
+	checks.Register("rate_limit_store", limiter.Ping)
+	checks.Register("access_rules", access.Ping)
+	// Informational only: a pod taken out of rotation would send nothing and
+	// never close its breakers, and failover covers a single open circuit
+	checks.RegisterInfo("provider_circuits", func() any { return dispatcher.Circuits() })
+	if err := jwt.SetKeys(jwtKeySet(cfg)); err != nil {
+		fatal(log, "error loading JWT keys", err)
+	}
+
+	// Swap policies and keys in place on SIGHUP or config file change. All
+	// that can fail is built first, so a rejected reload changes nothing.
+	watcher := config.NewWatcher(*configPath, cfg, log)
+	watcher.OnChange(func(c *config.Config) error {
+		level, err := logger.ParseLevel(c.Log.Level)
+		if err != nil {
+			return fmt.Errorf("log: %w", err)
+		}
+		keys := jwtKeySet(c)
+		if err := keys.Validate(); err != nil {
+			return fmt.Errorf("jwt: %w", err)
+		}
+		if len(c.OTP.TestNumbers) > 0 && cfg.Server.Mode == config.ModeProduction {
+			return errors.New("otp: test_numbers cannot be used, the server started in production mode")
+		}
+		templates, err := c.OTP.MessageTemplates()
+		if err != nil {
+			return fmt.Errorf("otp: %w", err)
+		}
+		db, dbPath := geoIP, geoIPPath
+		if c.Risk.GeoIPDB != geoIPPath {
+			if db, err = loadGeoIP(c.Risk.GeoIPDB); err != nil {
+				return fmt.Errorf("risk: geoip_db: %w", err)
+			}
+			dbPath = c.Risk.GeoIPDB
+		}
+
+		logLevel.Set(level)
+		_ = jwt.SetKeys(keys) // validated above
+		geoIP, geoIPPath = db, dbPath
+		otpSvc.SetPolicy(otpPolicy(c, cfg.Server.Mode))
+		mfaSvc.SetPolicy(mfaPolicy(c))
+		passkeySvc.SetPolicy(passkeyPolicy(c))
+		sessionSvc.SetPolicy(sessionPolicy(c))
+		riskSvc.SetPolicy(riskPolicy(c, geoIP))
+		access.SetGeoIP(geoIP)
+		otpMessages.SetTemplates(templates)
+		limiter.SetPolicies(rateLimitPolicies(c))
+		pow.SetDifficulty(c.Captcha.PoWDifficulty, time.Duration(c.Captcha.PoWTTL))
+		guard.SetPolicy(captchaPolicy(c), captchaVerifier(c, pow))
+		return nil
+	})
+
+	// Background workers run until the server has drained
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(fn func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			fn(workerCtx)
+		}()
+	}
+	sweepInterval := time.Duration(cfg.Server.SweepInterval)
+	runWorker(watcher.Run)
+	runWorker(func(ctx context.Context) { otpSvc.RunSweeper(ctx, sweepInterval) })
+	runWorker(func(ctx context.Context) { mfaSvc.RunSweeper(ctx, sweepInterval) })
+	runWorker(func(ctx context.Context) { passkeySvc.RunSweeper(ctx, sweepInterval) })
+	runWorker(func(ctx context.Context) { sessionSvc.RunSweeper(ctx, sweepInterval) })
+	runWorker(func(ctx context.Context) { riskSvc.RunSweeper(ctx, sweepInterval) })
+	runWorker(func(ctx context.Context) { limiter.RunSweeper(ctx, sweepInterval) })
+	runWorker(func(ctx context.Context) { pow.RunSweeper(ctx, sweepInterval) })
+	runWorker(func(ctx context.Context) { access.RunSweeper(ctx, sweepInterval) })
+	runWorker(dispatcher.Run)
+	runWorker(func(ctx context.Context) { dispatcher.RunSweeper(ctx, sweepInterval) })
 
 	// Create HTTP handlers
-	authCtrl := controller.NewAuthController(otpSvc, userSvc, limiter)
+	authCtrl := controller.NewAuthController(otpSvc, userSvc, mfaSvc, passkeySvc, sessionSvc, riskSvc, guard, access, limiter, dispatcher, log)
 	userCtrl := controller.NewUserController(userSvc)
+	mfaCtrl := controller.NewMFAController(mfaSvc)
+	passkeyCtrl := controller.NewPasskeyController(passkeySvc, userSvc)
+	sessionCtrl := controller.NewSessionController(sessionSvc)
+	healthCtrl := controller.NewHealthController(checks)
+
+	rt := router.New()
+	rt.Use(middleware.TraceRoute, middleware.RequestLogger(log))
+	adminCtrl := controller.NewAdminController(watcher, rt.DeprecatedUsage, dispatcher, access, log)
+	webhookCtrl := controller.NewWebhookController(dispatcher, webhookSecrets(cfg.Dispatch), log)
+
+	registerAPI(rt.Group("/v1"), authCtrl, userCtrl, mfaCtrl, passkeyCtrl, sessionCtrl, userSvc, sessionSvc)
+
+	// Unprefixed routes stay as deprecated aliases for already shipped clients
+	registerAPI(rt.Deprecated(router.Deprecation{
+		Since:           cfg.API.LegacyDeprecatedSince,
+		Sunset:          cfg.API.LegacySunset,
+		SuccessorPrefix: "/v1",
+	}), authCtrl, userCtrl, mfaCtrl, passkeyCtrl, sessionCtrl, userSvc, sessionSvc)
+
+	// Delivery reports from SMS providers, authenticated by HMAC signature
+	rt.HandleFunc("POST /webhooks/delivery/{provider}", webhookCtrl.DeliveryReportHandler)
+
+	// Kubernetes probes
+	rt.HandleFunc("GET /healthz", healthCtrl.LivenessHandler)
+	rt.HandleFunc("GET /readyz", healthCtrl.ReadinessHandler)
+
+	// Prometheus scrape endpoint
+	rt.Handle("GET /metrics", promhttp.Handler())
+
+	// Swagger UI (visit http://localhost:8080/swagger/v1/index.html)
+	rt.HandleFunc("GET /swagger/v1/", httpSwagger.Handler(httpSwagger.InstanceName(v1docs.SwaggerInfov1.InstanceName())))
+	rt.Handle("GET /swagger/{$}", http.RedirectHandler("/swagger/v1/index.html", http.StatusFound))
+	rt.Handle("GET /swagger/index.html", http.RedirectHandler("/swagger/v1/index.html", http.StatusFound))
+
+	// Request IDs are assigned before routing so 404/405 responses carry one too
+	handler := tracing.Handler(middleware.RequestID(middleware.Locale(middleware.AccessLog(log)(middleware.Metrics(rt)))))
+	srv := newServer(cfg.Server, cfg.Server.Addr, handler, log)
+	servers := []*http.Server{srv}
+
+	tlsCfg := cfg.Server.TLS
+	if tlsCfg.Enabled {
+		certs, err := certreloader.NewCertReloader(tlsCfg.CertFile, tlsCfg.KeyFile, log)
+		if err != nil {
+			fatal(log, "error loading TLS certificate", err)
+		}
+		runWorker(func(ctx context.Context) { certs.Run(ctx, 30*time.Second) })
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+
+		// Internal admin routes are only exposed to verified client certificates
+		if tlsCfg.ClientCAFile != "" {
+			pool, err := loadCertPool(tlsCfg.ClientCAFile)
+			if err != nil {
+				fatal(log, "error loading client CA", err)
+			}
+			srv.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			srv.TLSConfig.ClientCAs = pool
+			admin := rt.Group("/admin", middleware.RequireClientCert)
+			admin.HandleFunc("POST /config/reload", adminCtrl.ReloadConfigHandler)
+			admin.HandleFunc("GET /deprecations", adminCtrl.DeprecatedUsageHandler)
+			admin.HandleFunc("GET /deliveries", adminCtrl.DeliveryHistoryHandler)
+			admin.HandleFunc("GET /access-rules", adminCtrl.ListAccessRulesHandler)
+			admin.HandleFunc("POST /access-rules", adminCtrl.AddAccessRuleHandler)
+			admin.HandleFunc("DELETE /access-rules/{id}", adminCtrl.RemoveAccessRuleHandler)
+		}
+
+		if tlsCfg.RedirectAddr != "" {
+			servers = append(servers, newServer(cfg.Server, tlsCfg.RedirectAddr, redirectToHTTPS(cfg.Server.Addr), log))
+		}
+	}
+	if !tlsCfg.Enabled || tlsCfg.ClientCAFile == "" {
+		log.Warn("admin routes disabled: access rules, config reload and delivery history need server.tls with a client_ca_file")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() {
+			if s.TLSConfig != nil {
+				log.Info("starting HTTPS server", "addr", s.Addr)
+				serveErr <- s.ListenAndServeTLS("", "")
+				return
+			}
+			log.Info("starting server", "addr", s.Addr)
+			serveErr <- s.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal(log, "server failed", err)
+		}
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first so load balancers stop routing here, then stop
+	// accepting connections and let in-flight requests finish, then stop the
+	// workers they may depend on.
+	log.Info("shutting down server")
+	checks.SetShuttingDown()
+	time.Sleep(time.Duration(cfg.Server.ShutdownDelay))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			log.Warn("server did not drain in time", "addr", s.Addr, "err", err)
+		}
+	}
+	stopWorkers()
+	workers.Wait()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Warn("trace export did not flush", "err", err)
+	}
+	log.Info("server stopped")
+}
+
+// registerAPI mounts the public API on api; main mounts it once per version prefix.
+func registerAPI(api *router.Router, authCtrl *controller.AuthController, userCtrl *controller.UserController,
+	mfaCtrl *controller.MFAController, passkeyCtrl *controller.PasskeyController, sessionCtrl *controller.SessionController,
+	userSvc *service.UserService, sessionSvc *service.SessionService) {
+	jwtAuth := middleware.JWTAuth(sessionSvc)
 
 	// Public auth routes
-	http.HandleFunc("/auth/request-otp", authCtrl.RequestOTPHandler)
-	http.HandleFunc("/auth/verify", authCtrl.VerifyOTPHandler)
+	auth := api.Group("/auth")
+	auth.HandleFunc("GET /challenge", authCtrl.ChallengeHandler)
+	auth.HandleFunc("POST /request-otp", authCtrl.RequestOTPHandler)
+	auth.HandleFunc("POST /verify", authCtrl.VerifyOTPHandler)
+	auth.HandleFunc("GET /magic", authCtrl.MagicLinkHandler)
+	auth.HandleFunc("POST /mfa/verify", authCtrl.VerifyMFAHandler)
+	auth.HandleFunc("POST /passkey/options", authCtrl.PasskeyOptionsHandler)
+	auth.HandleFunc("POST /passkey/verify", authCtrl.PasskeyLoginHandler)
+	auth.HandleFunc("GET /deliveries/{id}", authCtrl.DeliveryStatusHandler)
 
 	// Protected user routes
-	http.Handle("/users", middleware.JWTAuth(http.HandlerFunc(userCtrl.ListUsersHandler)))
-	http.Handle("/users/", middleware.JWTAuth(http.HandlerFunc(userCtrl.GetUserHandler)))
+	users := api.Group("/users", jwtAuth, middleware.ProfileLocale(userSvc))
+	users.HandleFunc("GET", userCtrl.ListUsersHandler)
+	users.HandleFunc("GET /{phone}", userCtrl.GetUserHandler)
+
+	// Second factor of the signed-in user
+	mfa := api.Group("/mfa", jwtAuth, middleware.ProfileLocale(userSvc))
+	mfa.HandleFunc("POST /totp", mfaCtrl.EnrollTOTPHandler)
+	mfa.HandleFunc("POST /totp/confirm", mfaCtrl.ConfirmTOTPHandler)
+	mfa.HandleFunc("POST /totp/disable", mfaCtrl.DisableTOTPHandler)
+	mfa.HandleFunc("POST /recovery-codes", mfaCtrl.RecoveryCodesHandler)
+
+	// Passkeys of the signed-in user
+	passkeys := api.Group("/passkeys", jwtAuth, middleware.ProfileLocale(userSvc))
+	passkeys.HandleFunc("GET", passkeyCtrl.ListHandler)
+	passkeys.HandleFunc("POST", passkeyCtrl.RegisterHandler)
+	passkeys.HandleFunc("POST /register/options", passkeyCtrl.RegistrationOptionsHandler)
+	passkeys.HandleFunc("DELETE /{id}", passkeyCtrl.RemoveHandler)
+
+	// Sign-in sessions of the signed-in user
+	sessions := api.Group("/sessions", jwtAuth, middleware.ProfileLocale(userSvc))
+	sessions.HandleFunc("GET", sessionCtrl.ListHandler)
+	sessions.HandleFunc("DELETE /{id}", sessionCtrl.TerminateHandler)
+}
+
+func newServer(c config.ServerConfig, addr string, h http.Handler, log *slog.Logger) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ErrorLog:          slog.NewLogLogger(log.Handler(), slog.LevelWarn),
+		ReadTimeout:       time.Duration(c.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(c.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(c.WriteTimeout),
+		IdleTimeout:       time.Duration(c.IdleTimeout),
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+	}
+}
+
+// redirectToHTTPS sends every request to the same host and path on the HTTPS listener.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in " + file)
+	}
+	return pool, nil
+}
+
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// mustParseLevel parses a level already checked by config.Validate.
+func mustParseLevel(s string) slog.Level {
+	l, err := logger.ParseLevel(s)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// otpPolicy builds the OTP policy. Test numbers are only honoured when the
+// server started outside production mode; mode is read at startup only, so a
+// reload cannot turn them on in production.
+func otpPolicy(c *config.Config, mode string) otp.Policy {
+	var testNumbers map[string]string
+	if mode != config.ModeProduction {
+		testNumbers = make(map[string]string, len(c.OTP.TestNumbers))
+		for _, t := range c.OTP.TestNumbers {
+			testNumbers[t.Phone] = t.Code
+		}
+	}
+	return otp.Policy{
+		Length:       c.OTP.Length,
+		TTL:          time.Duration(c.OTP.TTL),
+		MaxAttempts:  c.OTP.MaxAttempts,
+		MagicLinkURL: c.OTP.MagicLinkURL,
+		TestNumbers:  testNumbers,
+	}
+}
+
+func mfaPolicy(c *config.Config) service.MFAPolicy {
+	return service.MFAPolicy{
+		Issuer:        c.MFA.Issuer,
+		ChallengeTTL:  time.Duration(c.MFA.ChallengeTTL),
+		MaxAttempts:   c.MFA.MaxAttempts,
+		RecoveryCodes: c.MFA.RecoveryCodes,
+	}
+}
+
+func sessionPolicy(c *config.Config) service.SessionPolicy {
+	return service.SessionPolicy{TTL: time.Duration(c.JWT.TTL), MaxPerUser: c.Session.MaxPerUser}
+}
 
-	// Swagger UI (visit http://localhost:8080/swagger/index.html)
-	http.HandleFunc("/swagger/", httpSwagger.WrapHandler)
+func riskPolicy(c *config.Config, geoIP *geoip.DB) service.RiskPolicy {
+	return service.RiskPolicy{
+		GeoIP:           geoIP,
+		Weights:         c.Risk.Weights,
+		StepUpScore:     c.Risk.StepUpScore,
+		BlockScore:      c.Risk.BlockScore,
+		TravelWindow:    time.Duration(c.Risk.TravelWindow),
+		RapidRequests:   c.Risk.RapidRequests,
+		RapidWindow:     time.Duration(c.Risk.RapidWindow),
+		Memory:          time.Duration(c.Risk.Memory),
+		NotifyNewDevice: c.Risk.NotifyNewDevice,
+	}
+}
+
+func captchaPolicy(c *config.Config) captcha.Policy {
+	return captcha.Policy{
+		Mode:              c.Captcha.Mode,
+		Provider:          c.Captcha.Provider,
+		SiteKey:           c.Captcha.SiteKey,
+		KeyThreshold:      c.Captcha.KeyThreshold,
+		PressureThreshold: c.Captcha.PressureThreshold,
+	}
+}
+
+// captchaVerifier returns the configured provider's verifier; pow is kept
+// across reloads so solved puzzles stay spent.
+func captchaVerifier(c *config.Config, pow *captcha.ProofOfWork) captcha.Verifier {
+	if c.Captcha.Provider == captcha.ProviderPoW {
+		return pow
+	}
+	verifyURL := c.Captcha.VerifyURL
+	if verifyURL == "" {
+		verifyURL = captcha.HostedURLs[c.Captcha.Provider]
+	}
+	return captcha.NewHosted(verifyURL, c.Captcha.Secret, time.Duration(c.Captcha.VerifyTimeout))
+}
+
+// loadGeoIP opens the GeoIP database at path; no path means none.
+func loadGeoIP(path string) (*geoip.DB, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return geoip.Open(path)
+}
+
+func passkeyPolicy(c *config.Config) service.PasskeyPolicy {
+	return service.PasskeyPolicy{
+		RP:         webauthn.RelyingParty{ID: c.Passkey.RPID, Name: c.Passkey.RPName, Origins: c.Passkey.Origins},
+		Timeout:    time.Duration(c.Passkey.Timeout),
+		MaxPending: c.Passkey.MaxPending,
+	}
+}
+
+// newDispatcher builds the OTP delivery queue with providers in config order,
+// which is the failover order within each channel.
+func newDispatcher(c config.DispatchConfig, log *slog.Logger) *dispatch.Dispatcher {
+	d := dispatch.NewDispatcher(dispatch.Options{
+		QueueSize:        c.QueueSize,
+		Workers:          c.Workers,
+		MaxAttempts:      c.MaxAttempts,
+		InitialBackoff:   time.Duration(c.InitialBackoff),
+		MaxBackoff:       time.Duration(c.MaxBackoff),
+		SendTimeout:      time.Duration(c.SendTimeout),
+		Retention:        time.Duration(c.StatusRetention),
+		HistoryRetention: time.Duration(c.HistoryRetention),
+		HistorySize:      c.HistorySize,
+		BreakerThreshold: c.BreakerThreshold,
+		BreakerCooldown:  time.Duration(c.BreakerCooldown),
+	}, log)
+	for _, p := range c.Providers {
+		switch p.Type {
+		case "console":
+			d.AddProvider(p.Channel, dispatch.NewConsoleProvider(p.Name, log))
+		case "http":
+			d.AddProvider(p.Channel, dispatch.NewHTTPProvider(p.Name, p.URL, p.APIKey, time.Duration(c.SendTimeout)))
+		case "voice":
+			d.AddProvider(p.Channel, dispatch.NewVoiceProvider(p.Name, p.URL, p.APIKey, time.Duration(c.SendTimeout)))
+		case "smtp":
+			smtp, err := dispatch.NewSMTPProvider(p.Name, p.Addr, p.From, p.Username, p.Password, log)
+			if err != nil {
+				fatal(log, "error configuring SMTP provider", err)
+			}
+			d.AddProvider(p.Channel, smtp)
+		}
+	}
+	return d
+}
+
+// webhookSecrets returns the delivery report secret of every provider that
+// has one; the others can't post reports.
+func webhookSecrets(c config.DispatchConfig) map[string][]byte {
+	secrets := make(map[string][]byte)
+	for _, p := range c.Providers {
+		if p.WebhookSecret != "" {
+			secrets[p.Name] = []byte(p.WebhookSecret)
+		}
+	}
+	return secrets
+}
+
+func rateLimitPolicies(c *config.Config) map[string]ratelimiter.Policy {
+	policies := make(map[string]ratelimiter.Policy, len(c.RateLimit))
+	for name, p := range c.RateLimit {
+		policies[name] = ratelimiter.Policy{Limit: p.Limit, Window: time.Duration(p.Window)}
+	}
+	return policies
+}
 
-	log.Println("Starting server on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+func jwtKeySet(c *config.Config) *jwt.KeySet {
+	ks := &jwt.KeySet{
+		Active: c.JWT.ActiveKey,
+		Keys:   make(map[string][]byte, len(c.JWT.Keys)),
+		TTL:    time.Duration(c.JWT.TTL),
+	}
+	for _, k := range c.JWT.Keys {
+		ks.Keys[k.ID] = []byte(k.Secret)
+	}
+	return ks
 }
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"dekamond-task/package/tracing"
+)
+
+type accessInfoKey struct{}
+
+// accessInfo lets handlers deeper in the chain report back to AccessLog.
+type accessInfo struct {
+	principal string
+}
+
+// setAccessPrincipal records the authenticated user for the access log line.
+func setAccessPrincipal(ctx context.Context, principal string) {
+	if ai, ok := ctx.Value(accessInfoKey{}).(*accessInfo); ok {
+		ai.principal = principal
+	}
+}
+
+// AccessLog writes one line per request once the response is complete.
+// It wraps the router, so the route is read from r.Pattern after routing.
+func AccessLog(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ai := &accessInfo{}
+			rec := &responseRecorder{ResponseWriter: w}
+			r = r.WithContext(context.WithValue(r.Context(), accessInfoKey{}, ai))
+
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			traceID, _ := tracing.IDs(r.Context())
+			log.LogAttrs(r.Context(), level, "http request",
+				slog.String("request_id", RequestIDFromContext(r.Context())),
+				slog.String("trace_id", traceID),
+				slog.String("method", r.Method),
+				slog.String("route", r.Pattern),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.status),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int("bytes", rec.bytes),
+				slog.String("client_ip", ClientIP(r)),
+				slog.String("user", ai.principal),
+			)
+		})
+	}
+}
+
+// responseRecorder captures the status code and body size.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
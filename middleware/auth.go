@@ -1,28 +1,62 @@
 package middleware
 
 import (
-	"dekamond-task/package/jwt"
+	"context"
+	"log/slog"
 	"net/http"
 	"strings"
+
+	"dekamond-task/package/jwt"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/response"
+	"dekamond-task/service"
 )
 
-func JWTAuth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"success":false,"message":"missing token"}`))
-			return
-		}
-
-		tokenStr := strings.TrimPrefix(auth, "Bearer ")
-		_, err := jwt.ValidateJWT(tokenStr)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"success":false,"message":"invalid token"}`))
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+type (
+	principalKey struct{}
+	sessionKey   struct{}
+)
+
+// Principal returns the phone or email of the authenticated user, if any.
+func Principal(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// SessionID returns the session the request's access token belongs to, if any.
+func SessionID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionKey{}).(string)
+	return id
+}
+
+// JWTAuth admits requests bearing a valid access token whose session is
+// still live in sessions, and records the activity on it.
+func JWTAuth(sessions *service.SessionService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+				response.Fail(w, r, response.ErrMissingToken)
+				return
+			}
+
+			tokenStr := strings.TrimPrefix(auth, "Bearer ")
+			sub, sid, err := jwt.ValidateJWT(tokenStr)
+			if err != nil {
+				response.Fail(w, r, err)
+				return
+			}
+			if err := sessions.Touch(sub, sid); err != nil {
+				response.Fail(w, r, err)
+				return
+			}
+
+			// Attach the principal to the context, the request logger and the access log
+			setAccessPrincipal(r.Context(), sub)
+			ctx := context.WithValue(r.Context(), principalKey{}, sub)
+			ctx = context.WithValue(ctx, sessionKey{}, sid)
+			ctx = logger.WithLogger(ctx, logger.FromContext(ctx, slog.Default()).With("principal", sub))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
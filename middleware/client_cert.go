@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"dekamond-task/package/response"
+)
+
+// RequireClientCert only lets through requests that presented a client
+// certificate verified against the configured client CA.
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			response.Fail(w, r, response.ErrClientCertRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
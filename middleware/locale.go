@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+
+	"dekamond-task/package/i18n"
+	"dekamond-task/service"
+)
+
+// Locale picks the response language from Accept-Language, stores it in the
+// request context and announces it in Content-Language, which the response
+// package reads when translating messages.
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale, _ := i18n.Match(r.Header.Get("Accept-Language"))
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", locale)
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
+
+// ProfileLocale falls back to the authenticated user's saved locale when the
+// request names no supported language. It must run after JWTAuth.
+func ProfileLocale(users *service.UserService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := i18n.Match(r.Header.Get("Accept-Language")); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			u, err := users.GetUser(Principal(r.Context()))
+			if err != nil || u.Locale == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Content-Language", u.Locale)
+			next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), u.Locale)))
+		})
+	}
+}
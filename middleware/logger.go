@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+
+	"dekamond-task/package/logger"
+	"dekamond-task/package/tracing"
+)
+
+// RequestLogger puts a logger carrying the request ID, trace ID, matched
+// route and client IP into the request context. It must run after routing so that
+// r.Pattern is set, i.e. be registered through the router.
+func RequestLogger(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceID, _ := tracing.IDs(r.Context())
+			l := base.With(
+				"request_id", RequestIDFromContext(r.Context()),
+				"trace_id", traceID,
+				"route", r.Pattern,
+				"client_ip", ClientIP(r),
+			)
+			next.ServeHTTP(w, r.WithContext(logger.WithLogger(r.Context(), l)))
+		})
+	}
+}
+
+// ClientIP returns the address the request came from, without the port.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
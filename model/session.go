@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Session is one sign-in of a user. Its ID is also the token family: every
+// access token issued for the sign-in carries it, and all of them stop
+// working once the session is terminated.
+type Session struct {
+	ID         string    `json:"id"`
+	User       string    `json:"user"` // phone or email
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
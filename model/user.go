@@ -1,8 +1,41 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
+// User is identified by either Phone or Email, whichever they signed up with.
 type User struct {
-	Phone        string    `json:"phone"`
+	Phone        string    `json:"phone,omitempty"`
+	Email        string    `json:"email,omitempty"`
 	RegisteredAt time.Time `json:"registered_at"`
+	Locale       string    `json:"locale"` // preferred language for messages and SMS
+	PasskeyID    []byte    `json:"-"`      // opaque WebAuthn user handle, set with the first passkey
+	Passkeys     []Passkey `json:"passkeys,omitempty"`
+}
+
+// Passkey is a WebAuthn credential a user signs in with instead of an OTP.
+// ID is the base64url credential ID.
+type Passkey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	PublicKey  []byte    `json:"public_key"` // COSE_Key
+	SignCount  uint32    `json:"sign_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// ID returns the identifier the user signs in with.
+func (u User) ID() string {
+	if u.Email != "" {
+		return u.Email
+	}
+	return u.Phone
+}
+
+// IsEmail reports whether a sign-in identifier is an email address rather
+// than a phone number.
+func IsEmail(identifier string) bool {
+	return strings.Contains(identifier, "@")
 }
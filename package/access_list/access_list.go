@@ -0,0 +1,333 @@
+package accesslist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"dekamond-task/package/geoip"
+	"dekamond-task/package/health"
+	"dekamond-task/package/metrics"
+	"dekamond-task/package/schedule"
+)
+
+var (
+	ErrDenied       = errors.New("OTP requests are blocked for this number or network")
+	ErrRuleNotFound = errors.New("access rule not found")
+	ErrInvalidRule  = errors.New("invalid access rule")
+)
+
+// Rule kinds, i.e. what a rule's value matches.
+const (
+	KindCIDR    = "cidr"    // client IP range, e.g. 203.0.113.0/24
+	KindPrefix  = "prefix"  // phone number prefix, e.g. 0990
+	KindNumber  = "number"  // exact phone number
+	KindCountry = "country" // client IP's country from the GeoIP database, e.g. RU
+)
+
+// Rule actions. An allow rule names a number whose requests skip the bot
+// challenge and the rate limiter; deny rules win over allow rules.
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+)
+
+var (
+	phonePrefix = regexp.MustCompile(`^0[0-9]{1,10}$`)
+	phoneNumber = regexp.MustCompile(`^09[0-9]{9}$`)
+	countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// Rule matches OTP requests by client network or phone number. A zero
+// ExpiresAt never expires.
+type Rule struct {
+	ID        string     `json:"id"`
+	Kind      string     `json:"kind"`
+	Value     string     `json:"value"`
+	Action    string     `json:"action"`
+	Note      string     `json:"note,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Hits      int64      `json:"-"` // since startup
+
+	prefix netip.Prefix // parsed Value of a CIDR rule
+}
+
+// Expired reports whether the rule has lapsed at now.
+func (r Rule) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// Request is what rules are evaluated against.
+type Request struct {
+	Phone string // empty for email requests
+	IP    string
+}
+
+// List holds the rules, persisted as JSON to a file so they survive
+// restarts.
+type List struct {
+	mu    sync.Mutex
+	path  string
+	geo   *geoip.DB
+	rules []*Rule
+	log   *slog.Logger
+}
+
+// Open loads the rules saved at path; a missing file is an empty list.
+// Expired rules are dropped.
+func Open(path string, geo *geoip.DB, log *slog.Logger) (*List, error) {
+	l := &List{path: path, geo: geo, log: log}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []*Rule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	now := time.Now()
+	for _, r := range rules {
+		if err := normalize(r); err != nil {
+			return nil, fmt.Errorf("%s: rule %s: %w", path, r.ID, err)
+		}
+		if !r.Expired(now) {
+			l.rules = append(l.rules, r)
+		}
+	}
+	return l, nil
+}
+
+// SetGeoIP swaps the database country rules are matched with; without one
+// they match nothing.
+func (l *List) SetGeoIP(geo *geoip.DB) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.geo = geo
+}
+
+// Check evaluates req and returns the rule that decides it, or nil when no
+// rule matches: the first matching deny rule, else a matching allow rule.
+// Every decision is counted on its rule.
+func (l *List) Check(req Request) *Rule {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	addr, err := netip.ParseAddr(req.IP)
+	ipOK := err == nil
+	var country string
+	if ipOK && l.geo != nil {
+		if loc, ok := l.geo.Lookup(addr); ok {
+			country = loc.Country
+		}
+	}
+
+	var decided *Rule
+	for _, r := range l.rules {
+		if r.Expired(now) {
+			continue
+		}
+		var match bool
+		switch r.Kind {
+		case KindCIDR:
+			match = ipOK && r.prefix.Contains(addr.Unmap())
+		case KindPrefix:
+			match = req.Phone != "" && strings.HasPrefix(req.Phone, r.Value)
+		case KindNumber:
+			match = req.Phone == r.Value
+		case KindCountry:
+			match = country == r.Value
+		}
+		if !match {
+			continue
+		}
+		if r.Action == ActionDeny {
+			decided = r
+			break
+		}
+		if decided == nil {
+			decided = r
+		}
+	}
+	if decided == nil {
+		return nil
+	}
+	decided.Hits++
+	metrics.AccessRuleHits.WithLabelValues(decided.Kind, decided.Action).Inc()
+	hit := *decided
+	return &hit
+}
+
+// Add validates r, assigns its ID and saves it.
+func (l *List) Add(r Rule) (Rule, error) {
+	if r.Action != ActionAllow && r.Action != ActionDeny {
+		return Rule{}, fmt.Errorf("%w: unknown action %q", ErrInvalidRule, r.Action)
+	}
+	if err := normalize(&r); err != nil {
+		return Rule{}, err
+	}
+	now := time.Now()
+	if r.Expired(now) {
+		return Rule{}, fmt.Errorf("%w: expires_at is in the past", ErrInvalidRule)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Rule{}, fmt.Errorf("generate rule ID: %w", err)
+	}
+	r.ID, r.CreatedAt, r.Hits = hex.EncodeToString(id), now.Truncate(time.Second), 0
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rules := append(slices.Clip(l.rules), &r)
+	if err := l.save(rules); err != nil {
+		return Rule{}, err
+	}
+	l.rules = rules
+	return r, nil
+}
+
+// Remove deletes the rule with id.
+func (l *List) Remove(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := slices.IndexFunc(l.rules, func(r *Rule) bool { return r.ID == id })
+	if i < 0 {
+		return ErrRuleNotFound
+	}
+	rules := slices.Delete(slices.Clone(l.rules), i, i+1)
+	if err := l.save(rules); err != nil {
+		return err
+	}
+	l.rules = rules
+	return nil
+}
+
+// Rules returns the live rules, oldest first.
+func (l *List) Rules() []Rule {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	out := make([]Rule, 0, len(l.rules))
+	for _, r := range l.rules {
+		if !r.Expired(now) {
+			out = append(out, *r)
+		}
+	}
+	return out
+}
+
+// normalize checks r's value against its kind and puts it in canonical
+// form: CIDRs masked (a bare address becomes a /32 or /128) and countries
+// upper case. Only numbers can be allowed.
+func normalize(r *Rule) error {
+	if r.Action == ActionAllow && r.Kind != KindNumber {
+		return fmt.Errorf("%w: only a number can be allowed, not a %s", ErrInvalidRule, r.Kind)
+	}
+	v := strings.TrimSpace(r.Value)
+	switch r.Kind {
+	case KindCIDR:
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(v)
+			if aerr != nil {
+				return fmt.Errorf("%w: %q is not an IP range", ErrInvalidRule, v)
+			}
+			addr = addr.Unmap()
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		r.prefix = p.Masked()
+		v = r.prefix.String()
+	case KindPrefix:
+		if !phonePrefix.MatchString(v) {
+			return fmt.Errorf("%w: %q is not a phone prefix", ErrInvalidRule, v)
+		}
+	case KindNumber:
+		if !phoneNumber.MatchString(v) {
+			return fmt.Errorf("%w: %q is not a phone number (09XXXXXXXXX)", ErrInvalidRule, v)
+		}
+	case KindCountry:
+		v = strings.ToUpper(v)
+		if !countryCode.MatchString(v) {
+			return fmt.Errorf("%w: %q is not a two-letter country code", ErrInvalidRule, v)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidRule, r.Kind)
+	}
+	r.Value = v
+	return nil
+}
+
+// save writes rules to the file, replacing it atomically so a crash never
+// leaves it half written; the caller holds mu.
+func (l *List) save(rules []*Rule) error {
+	b, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return fmt.Errorf("save access rules: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save access rules: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save access rules: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("save access rules: %w", err)
+	}
+	return nil
+}
+
+// Ping reports whether the list can be locked before ctx is done.
+func (l *List) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, l.mu.TryLock); err != nil {
+		return err
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+// RunSweeper drops expired rules from the list and its file every interval
+// until ctx is done.
+func (l *List) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, l.sweep)
+}
+
+func (l *List) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	rules := slices.DeleteFunc(slices.Clone(l.rules), func(r *Rule) bool { return r.Expired(now) })
+	if len(rules) == len(l.rules) {
+		return
+	}
+	if err := l.save(rules); err != nil {
+		l.log.Error("expired access rules not removed", "err", err)
+		return
+	}
+	for _, r := range l.rules {
+		if r.Expired(now) {
+			l.log.Info("access rule expired", "rule_id", r.ID, "kind", r.Kind, "value", r.Value, "action", r.Action)
+		}
+	}
+	l.rules = rules
+}
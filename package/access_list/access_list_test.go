@@ -0,0 +1,82 @@
+package accesslist
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func newTestList(t *testing.T, rules ...Rule) *List {
+	t.Helper()
+	l, err := Open(filepath.Join(t.TempDir(), "rules.json"), nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, r := range rules {
+		if _, err := l.Add(r); err != nil {
+			t.Fatalf("Add(%+v): %v", r, err)
+		}
+	}
+	return l
+}
+
+func TestCheckDenyWins(t *testing.T) {
+	allowNumber := Rule{Kind: KindNumber, Value: "09901234567", Action: ActionAllow}
+	tests := []struct {
+		name       string
+		rules      []Rule
+		req        Request
+		wantAction string // empty when no rule decides
+		wantKind   string
+	}{
+		{"no rules", nil, Request{Phone: "09901234567", IP: "203.0.113.7"}, "", ""},
+		{"allowed number", []Rule{allowNumber}, Request{Phone: "09901234567", IP: "203.0.113.7"}, ActionAllow, KindNumber},
+		{"other number", []Rule{allowNumber}, Request{Phone: "09901234568", IP: "203.0.113.7"}, "", ""},
+		{"denied prefix beats allowed number", []Rule{allowNumber, {Kind: KindPrefix, Value: "0990", Action: ActionDeny}},
+			Request{Phone: "09901234567", IP: "203.0.113.7"}, ActionDeny, KindPrefix},
+		{"denied network beats allowed number", []Rule{{Kind: KindCIDR, Value: "203.0.113.0/24", Action: ActionDeny}, allowNumber},
+			Request{Phone: "09901234567", IP: "203.0.113.7"}, ActionDeny, KindCIDR},
+		{"denied number beats allowed number", []Rule{allowNumber, {Kind: KindNumber, Value: "09901234567", Action: ActionDeny}},
+			Request{Phone: "09901234567", IP: "203.0.113.7"}, ActionDeny, KindNumber},
+		{"email requests match no number", []Rule{allowNumber}, Request{IP: "203.0.113.7"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestList(t, tt.rules...)
+			got := l.Check(tt.req)
+			if tt.wantAction == "" {
+				if got != nil {
+					t.Fatalf("Check() = %+v, want no rule", *got)
+				}
+				return
+			}
+			if got == nil || got.Action != tt.wantAction || got.Kind != tt.wantKind {
+				t.Fatalf("Check() = %+v, want a %s %s rule", got, tt.wantKind, tt.wantAction)
+			}
+			if got.Hits != 1 {
+				t.Errorf("Hits = %d, want 1", got.Hits)
+			}
+		})
+	}
+}
+
+func TestAddOnlyAllowsNumbers(t *testing.T) {
+	tests := []struct {
+		rule    Rule
+		wantErr error
+	}{
+		{Rule{Kind: KindNumber, Value: "09901234567", Action: ActionAllow}, nil},
+		{Rule{Kind: KindPrefix, Value: "0990", Action: ActionAllow}, ErrInvalidRule},
+		{Rule{Kind: KindCIDR, Value: "10.0.0.0/8", Action: ActionAllow}, ErrInvalidRule},
+		{Rule{Kind: KindCountry, Value: "ir", Action: ActionAllow}, ErrInvalidRule},
+		{Rule{Kind: KindCountry, Value: "ir", Action: ActionDeny}, nil},
+	}
+	for _, tt := range tests {
+		l := newTestList(t)
+		if _, err := l.Add(tt.rule); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Add(%s %s %s) = %v, want %v", tt.rule.Action, tt.rule.Kind, tt.rule.Value, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"dekamond-task/package/metrics"
+)
+
+var (
+	ErrRequired    = errors.New("solve a challenge from /auth/challenge and send its answer")
+	ErrFailed      = errors.New("challenge answer is wrong, expired or already used")
+	ErrUnavailable = errors.New("challenge verification is unavailable; try again shortly")
+)
+
+// Modes decide when OTP requests need a solved challenge.
+const (
+	ModeOff      = "off"
+	ModeAdaptive = "adaptive" // only under rate-limit pressure
+	ModeAlways   = "always"
+)
+
+// ProviderPoW is the self-hosted proof of work; other providers are hosted
+// CAPTCHAs, see HostedURLs.
+const ProviderPoW = "pow"
+
+// Verifier checks the answer a client sends with an OTP request: a solved
+// puzzle or a hosted CAPTCHA's response token.
+type Verifier interface {
+	Verify(ctx context.Context, answer, remoteIP string) error
+}
+
+// Issuer is a Verifier that hands out its own puzzles.
+type Issuer interface {
+	Verifier
+	Issue() (Puzzle, error)
+}
+
+// Pressure is what the adaptive mode watches, i.e. the rate limiter.
+type Pressure interface {
+	// Used returns how many requests key made within the policy's window.
+	Used(policy, key string) int
+	// RecentRejections returns how many requests were rejected in the last minute.
+	RecentRejections() int
+}
+
+// Policy tunes the guard. In adaptive mode a challenge is needed once the
+// identifier has made KeyThreshold requests in its rate-limit window, or for
+// everyone while more than PressureThreshold requests a minute are being
+// rate limited.
+type Policy struct {
+	Mode              string
+	Provider          string
+	SiteKey           string // hosted CAPTCHA widget key, passed on to clients
+	KeyThreshold      int
+	PressureThreshold int
+}
+
+// Challenge tells a client how to prove it is not a bot: solve Puzzle with
+// the self-hosted provider, or render the hosted widget with SiteKey.
+type Challenge struct {
+	Provider string
+	SiteKey  string
+	Puzzle   *Puzzle
+}
+
+// Guard puts a challenge in front of OTP requests when policy calls for it.
+type Guard struct {
+	mu       sync.RWMutex
+	policy   Policy
+	verifier Verifier
+	pressure Pressure
+}
+
+func NewGuard(p Policy, v Verifier, pressure Pressure) *Guard {
+	return &Guard{policy: p, verifier: v, pressure: pressure}
+}
+
+// SetPolicy swaps the policy and the verifier of its provider.
+func (g *Guard) SetPolicy(p Policy, v Verifier) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.policy, g.verifier = p, v
+}
+
+// Required reports whether a request for key under the rate-limit policy
+// needs a solved challenge.
+func (g *Guard) Required(limitPolicy, key string) bool {
+	g.mu.RLock()
+	p := g.policy
+	g.mu.RUnlock()
+	switch p.Mode {
+	case ModeAlways:
+		return true
+	case ModeAdaptive:
+		return g.pressure.Used(limitPolicy, key) >= p.KeyThreshold ||
+			g.pressure.RecentRejections() > p.PressureThreshold
+	}
+	return false
+}
+
+// Verify checks answer with the current provider.
+func (g *Guard) Verify(ctx context.Context, answer, remoteIP string) error {
+	g.mu.RLock()
+	provider, v := g.policy.Provider, g.verifier
+	g.mu.RUnlock()
+
+	if answer == "" {
+		metrics.ChallengeVerifications.WithLabelValues(provider, "missing").Inc()
+		return ErrRequired
+	}
+	err := v.Verify(ctx, answer, remoteIP)
+	outcome := "ok"
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		outcome = "error"
+	case err != nil:
+		outcome = "failed"
+	}
+	metrics.ChallengeVerifications.WithLabelValues(provider, outcome).Inc()
+	return err
+}
+
+// Issue returns the challenge for the current provider, with a fresh puzzle
+// when the provider issues its own.
+func (g *Guard) Issue() (Challenge, error) {
+	g.mu.RLock()
+	p, v := g.policy, g.verifier
+	g.mu.RUnlock()
+
+	c := Challenge{Provider: p.Provider, SiteKey: p.SiteKey}
+	if issuer, ok := v.(Issuer); ok {
+		puzzle, err := issuer.Issue()
+		if err != nil {
+			return Challenge{}, err
+		}
+		c.Puzzle = &puzzle
+	}
+	return c, nil
+}
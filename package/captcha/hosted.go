@@ -0,0 +1,63 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HostedURLs are the siteverify endpoints of the hosted CAPTCHAs, which
+// share one API.
+var HostedURLs = map[string]string{
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+// Hosted verifies response tokens of a hosted CAPTCHA widget with its
+// siteverify endpoint.
+type Hosted struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewHosted(verifyURL, secret string, timeout time.Duration) *Hosted {
+	return &Hosted{url: verifyURL, secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *Hosted) Verify(ctx context.Context, answer, remoteIP string) error {
+	form := url.Values{"secret": {h.secret}, "response": {answer}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: siteverify returned %s", ErrUnavailable, resp.Status)
+	}
+	var out struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&out); err != nil {
+		return fmt.Errorf("%w: siteverify response: %v", ErrUnavailable, err)
+	}
+	if !out.Success {
+		return fmt.Errorf("%w: %s", ErrFailed, strings.Join(out.ErrorCodes, ", "))
+	}
+	return nil
+}
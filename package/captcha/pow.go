@@ -0,0 +1,146 @@
+package captcha
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dekamond-task/package/schedule"
+)
+
+// maxNonce caps the nonce a client may send with a solution.
+const maxNonce = 64
+
+// Puzzle is a hashcash-style proof of work: find a nonce such that
+// SHA-256(Challenge + ":" + nonce) starts with Difficulty zero bits, and
+// answer with Challenge + ":" + nonce before ExpiresAt.
+type Puzzle struct {
+	Challenge  string
+	Difficulty int
+	Algorithm  string
+	ExpiresAt  time.Time
+}
+
+// ProofOfWork issues and checks puzzles. Puzzles are signed rather than
+// stored, so issuing costs no memory; solved ones are kept until they expire
+// so each works once.
+type ProofOfWork struct {
+	mu         sync.Mutex
+	key        []byte
+	difficulty int
+	ttl        time.Duration
+	spent      map[string]time.Time // puzzle ID -> expiry
+}
+
+// NewProofOfWork creates a ProofOfWork with a random signing key; puzzles
+// from a previous run are refused.
+func NewProofOfWork(difficulty int, ttl time.Duration) (*ProofOfWork, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate proof-of-work key: %w", err)
+	}
+	return &ProofOfWork{key: key, difficulty: difficulty, ttl: ttl, spent: make(map[string]time.Time)}, nil
+}
+
+// SetDifficulty applies to puzzles issued from now on.
+func (p *ProofOfWork) SetDifficulty(difficulty int, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.difficulty, p.ttl = difficulty, ttl
+}
+
+// Issue returns a fresh puzzle.
+func (p *ProofOfWork) Issue() (Puzzle, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Puzzle{}, fmt.Errorf("generate puzzle: %w", err)
+	}
+	p.mu.Lock()
+	difficulty, expires := p.difficulty, time.Now().Add(p.ttl).Truncate(time.Second)
+	p.mu.Unlock()
+
+	// id.expiry.difficulty.signature
+	body := fmt.Sprintf("%x.%d.%d", id, expires.Unix(), difficulty)
+	return Puzzle{
+		Challenge:  body + "." + p.sign(body),
+		Difficulty: difficulty,
+		Algorithm:  "sha256",
+		ExpiresAt:  expires,
+	}, nil
+}
+
+// Verify checks a solution, answer being the puzzle's challenge and the
+// nonce joined by ":".
+func (p *ProofOfWork) Verify(_ context.Context, answer, _ string) error {
+	challenge, nonce, ok := strings.Cut(answer, ":")
+	if !ok || nonce == "" || len(nonce) > maxNonce {
+		return fmt.Errorf("%w: malformed answer", ErrFailed)
+	}
+	fields := strings.Split(challenge, ".")
+	if len(fields) != 4 {
+		return fmt.Errorf("%w: malformed challenge", ErrFailed)
+	}
+	body := strings.Join(fields[:3], ".")
+	if !hmac.Equal([]byte(fields[3]), []byte(p.sign(body))) {
+		return fmt.Errorf("%w: bad signature", ErrFailed)
+	}
+	// Signed by us, so the fields are well formed
+	unix, _ := strconv.ParseInt(fields[1], 10, 64)
+	difficulty, _ := strconv.Atoi(fields[2])
+	expires := time.Unix(unix, 0)
+	if time.Now().After(expires) {
+		return fmt.Errorf("%w: expired", ErrFailed)
+	}
+	if leadingZeroBits(sha256.Sum256([]byte(answer))) < difficulty {
+		return fmt.Errorf("%w: not enough work", ErrFailed)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, used := p.spent[fields[0]]; used {
+		return fmt.Errorf("%w: already used", ErrFailed)
+	}
+	p.spent[fields[0]] = expires
+	return nil
+}
+
+func (p *ProofOfWork) sign(body string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// RunSweeper forgets expired solved puzzles every interval until ctx is done.
+func (p *ProofOfWork) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, p.sweep)
+}
+
+func (p *ProofOfWork) sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for id, expires := range p.spent {
+		if now.After(expires) {
+			delete(p.spent, id)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package certreloader
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertReloader serves a certificate from disk and picks up replacements
+// (e.g. renewals) without a restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	log      *slog.Logger
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewCertReloader loads the key pair; it fails if the initial pair is invalid.
+func NewCertReloader(certFile, keyFile string, log *slog.Logger) (*CertReloader, error) {
+	cr := &CertReloader{certFile: certFile, keyFile: keyFile, log: log}
+	if err := cr.Reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate.
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.cert.Load(), nil
+}
+
+// Reload reads the key pair again; on error the previous certificate stays live.
+func (cr *CertReloader) Reload() error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	mod := cr.latestModTime()
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.cert.Store(&cert)
+	cr.modTime = mod
+	return nil
+}
+
+// Run checks the files every interval and reloads when either changed.
+func (cr *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cr.mu.Lock()
+			changed := !cr.latestModTime().Equal(cr.modTime)
+			cr.mu.Unlock()
+			if !changed {
+				continue
+			}
+			if err := cr.Reload(); err != nil {
+				cr.log.Error("TLS certificate reload failed, keeping previous certificate", "err", err)
+				continue
+			}
+			cr.log.Info("TLS certificate reloaded", "cert_file", cr.certFile)
+		}
+	}
+}
+
+func (cr *CertReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{cr.certFile, cr.keyFile} {
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
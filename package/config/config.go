@@ -0,0 +1,722 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"dekamond-task/package/captcha"
+	"dekamond-task/package/i18n"
+	otptemplate "dekamond-task/package/otp_template"
+)
+
+// Config holds the runtime settings. Everything except Server can be reloaded
+// without a restart.
+type Config struct {
+	Server    ServerConfig               `json:"server"`
+	API       APIConfig                  `json:"api"`
+	Log       LogConfig                  `json:"log"`
+	Tracing   TracingConfig              `json:"tracing"`
+	JWT       JWTConfig                  `json:"jwt"`
+	OTP       OTPConfig                  `json:"otp"`
+	MFA       MFAConfig                  `json:"mfa"`
+	Passkey   PasskeyConfig              `json:"passkey"`
+	Session   SessionConfig              `json:"session"`
+	Risk      RiskConfig                 `json:"risk"`
+	Captcha   CaptchaConfig              `json:"captcha"`
+	Access    AccessConfig               `json:"access"`
+	Dispatch  DispatchConfig             `json:"dispatch"`
+	RateLimit map[string]RateLimitPolicy `json:"rate_limit"` // policy name -> limits
+}
+
+// ServerConfig tunes the HTTP server; it is only read at startup. Mode
+// production refuses testing aids such as OTP test numbers.
+type ServerConfig struct {
+	Mode              string    `json:"mode"` // production, staging or development
+	Addr              string    `json:"addr"`
+	ReadTimeout       Duration  `json:"read_timeout"`
+	ReadHeaderTimeout Duration  `json:"read_header_timeout"`
+	WriteTimeout      Duration  `json:"write_timeout"`
+	IdleTimeout       Duration  `json:"idle_timeout"`
+	MaxHeaderBytes    int       `json:"max_header_bytes"`
+	ShutdownTimeout   Duration  `json:"shutdown_timeout"`
+	ShutdownDelay     Duration  `json:"shutdown_delay"` // keep serving while probes see not-ready
+	SweepInterval     Duration  `json:"sweep_interval"`
+	TLS               TLSConfig `json:"tls"`
+}
+
+// Server modes.
+const (
+	ModeProduction  = "production"
+	ModeStaging     = "staging"
+	ModeDevelopment = "development"
+)
+
+// TLSConfig enables HTTPS on Addr. The cert and key are reloaded when they
+// change on disk; ClientCAFile turns on client-certificate checks for admin
+// routes and RedirectAddr serves an HTTP listener that redirects to HTTPS.
+type TLSConfig struct {
+	Enabled      bool   `json:"enabled"`
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file"`
+	RedirectAddr string `json:"redirect_addr"`
+}
+
+// APIConfig controls the unprefixed aliases of the /v1 routes; it is only
+// read at startup. Without LegacySunset no Sunset header is sent.
+type APIConfig struct {
+	LegacyDeprecatedSince time.Time  `json:"legacy_deprecated_since"`
+	LegacySunset          *time.Time `json:"legacy_sunset,omitempty"`
+}
+
+// LogConfig selects the log format; Level can be changed on reload.
+type LogConfig struct {
+	Format string `json:"format"` // json or text
+	Level  string `json:"level"`  // debug, info, warn or error
+	Redact bool   `json:"redact"` // mask phone numbers and OTP codes
+}
+
+// TracingConfig sets up OpenTelemetry export over OTLP/HTTP; it is only read
+// at startup.
+type TracingConfig struct {
+	Enabled     bool    `json:"enabled"`
+	Endpoint    string  `json:"endpoint"` // collector host:port
+	Insecure    bool    `json:"insecure"`
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// JWTConfig lists the signing keys; ActiveKey signs new tokens, the rest only verify.
+type JWTConfig struct {
+	ActiveKey string   `json:"active_key"`
+	Keys      []JWTKey `json:"keys"`
+	TTL       Duration `json:"ttl"`
+}
+
+type JWTKey struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type OTPConfig struct {
+	Length       int             `json:"length"`
+	TTL          Duration        `json:"ttl"`
+	MaxAttempts  int             `json:"max_attempts"`
+	MagicLinkURL string          `json:"magic_link_url"` // magic links point here with ?token=; empty disables them
+	DefaultApp   string          `json:"default_app"`    // used when a request names no known app
+	Apps         []OTPApp        `json:"apps"`
+	Templates    []OTPTemplate   `json:"templates"`
+	TestNumbers  []OTPTestNumber `json:"test_numbers,omitempty"` // refused in production mode
+}
+
+// OTPTestNumber is a phone number that gets the fixed Code instead of an
+// SMS, and skips the rate limit, for app store reviewers and automated UI
+// tests.
+type OTPTestNumber struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+	Note  string `json:"note,omitempty"` // who uses it
+}
+
+// OTPApp is a client app; Domain adds the iOS "@domain #code" autofill line
+// and AndroidHash the SMS Retriever app hash.
+type OTPApp struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Domain      string `json:"domain"`
+	AndroidHash string `json:"android_hash"`
+}
+
+// OTPTemplate is a text/template for one channel and locale; App limits it
+// to a single app.
+type OTPTemplate struct {
+	Channel string `json:"channel"`
+	Locale  string `json:"locale"`
+	App     string `json:"app"`
+	Text    string `json:"text"`
+}
+
+// MessageTemplates compiles the OTP message templates.
+func (c OTPConfig) MessageTemplates() (*otptemplate.Set, error) {
+	apps := make([]otptemplate.App, len(c.Apps))
+	for i, a := range c.Apps {
+		apps[i] = otptemplate.App(a)
+	}
+	templates := make([]otptemplate.Template, len(c.Templates))
+	for i, t := range c.Templates {
+		templates[i] = otptemplate.Template(t)
+	}
+	return otptemplate.Compile(c.DefaultApp, i18n.Default, apps, templates)
+}
+
+// MFAConfig tunes the TOTP second factor. Issuer labels the account in
+// authenticator apps; a sign-in challenge allows MaxAttempts codes within
+// ChallengeTTL.
+type MFAConfig struct {
+	Issuer        string   `json:"issuer"`
+	ChallengeTTL  Duration `json:"challenge_ttl"`
+	MaxAttempts   int      `json:"max_attempts"`
+	RecoveryCodes int      `json:"recovery_codes"` // issued on enrollment
+}
+
+// PasskeyConfig sets the WebAuthn relying party. RPID is the domain passkeys
+// are bound to, so changing it orphans existing passkeys; Origins are the
+// pages allowed to run ceremonies.
+type PasskeyConfig struct {
+	RPID       string   `json:"rp_id"`
+	RPName     string   `json:"rp_name"`
+	Origins    []string `json:"origins"`
+	Timeout    Duration `json:"timeout"`
+	MaxPending int      `json:"max_pending"` // open ceremonies across all users
+}
+
+// SessionConfig limits sign-in sessions, which last jwt.ttl. Signing in on
+// more than MaxPerUser devices signs out the least recently seen one.
+type SessionConfig struct {
+	MaxPerUser int `json:"max_per_user"`
+}
+
+// RiskConfig scores sign-ins that passed their first factor. Each signal
+// raised adds its weight; a total of StepUpScore or more needs a second
+// factor and BlockScore or more is refused. GeoIPDB is an ip2asn TSV file
+// for the country and network signals; empty turns them off. Without
+// coordinates, impossible travel is a sign-in from another country within
+// TravelWindow of the last one.
+type RiskConfig struct {
+	GeoIPDB         string         `json:"geoip_db"`
+	Weights         map[string]int `json:"weights"` // signal -> score
+	StepUpScore     int            `json:"step_up_score"`
+	BlockScore      int            `json:"block_score"`
+	TravelWindow    Duration       `json:"travel_window"`
+	RapidRequests   int            `json:"rapid_requests"` // OTP requests within RapidWindow that raise rapid_requests
+	RapidWindow     Duration       `json:"rapid_window"`
+	Memory          Duration       `json:"memory"` // how long devices, countries and networks are remembered
+	NotifyNewDevice bool           `json:"notify_new_device"`
+}
+
+// RiskSignals are the signals RiskConfig.Weights can weigh.
+var RiskSignals = []string{"new_device", "new_country", "new_asn", "impossible_travel", "rapid_requests"}
+
+// CaptchaConfig puts a bot challenge in front of OTP requests. Mode "off"
+// never asks, "always" always does and "adaptive" asks once an identifier
+// has made KeyThreshold requests in its rate-limit window, or from everyone
+// while more than PressureThreshold requests a minute are rate limited.
+// Provider "pow" is a self-hosted proof of work of PoWDifficulty bits;
+// "recaptcha", "hcaptcha" and "turnstile" check the widget's token with
+// Secret at the provider, or at VerifyURL if set.
+type CaptchaConfig struct {
+	Mode              string   `json:"mode"`
+	Provider          string   `json:"provider"`
+	SiteKey           string   `json:"site_key,omitempty"`
+	Secret            string   `json:"secret,omitempty"`
+	VerifyURL         string   `json:"verify_url,omitempty"`
+	VerifyTimeout     Duration `json:"verify_timeout"`
+	PoWDifficulty     int      `json:"pow_difficulty"`
+	PoWTTL            Duration `json:"pow_ttl"` // how long a puzzle can be solved for
+	KeyThreshold      int      `json:"key_threshold"`
+	PressureThreshold int      `json:"pressure_threshold"`
+}
+
+// AccessConfig locates the allow and deny rules for OTP requests, managed
+// through /admin/access-rules; it is only read at startup.
+type AccessConfig struct {
+	RulesFile string `json:"rules_file"`
+}
+
+// DispatchConfig sizes the OTP delivery queue and lists the providers of each
+// channel in failover order; it is only read at startup.
+type DispatchConfig struct {
+	QueueSize        int              `json:"queue_size"`
+	Workers          int              `json:"workers"`
+	MaxAttempts      int              `json:"max_attempts"`
+	InitialBackoff   Duration         `json:"initial_backoff"`
+	MaxBackoff       Duration         `json:"max_backoff"`
+	SendTimeout      Duration         `json:"send_timeout"`
+	StatusRetention  Duration         `json:"status_retention"`
+	HistoryRetention Duration         `json:"history_retention"` // per-recipient delivery history for support
+	HistorySize      int              `json:"history_size"`
+	BreakerThreshold int              `json:"breaker_threshold"` // consecutive failures that open a provider's circuit
+	BreakerCooldown  Duration         `json:"breaker_cooldown"`
+	Providers        []ProviderConfig `json:"providers"`
+}
+
+// ProviderConfig is a delivery provider. Type "console" logs messages, "http"
+// posts them to an SMS gateway at URL, "voice" has the text-to-speech API at
+// URL call and read them out and "smtp" mails them through Addr.
+// WebhookSecret enables the delivery report webhook.
+type ProviderConfig struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Channel       string `json:"channel"`
+	URL           string `json:"url,omitempty"`
+	APIKey        string `json:"api_key,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	Addr          string `json:"addr,omitempty"` // SMTP host:port
+	From          string `json:"from,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+}
+
+// OTPRateLimitPolicy names the rate-limit policy for OTP requests on channel.
+// SMS keeps the original "otp" policy; other channels use "otp_<channel>".
+func OTPRateLimitPolicy(channel string) string {
+	if channel == otptemplate.ChannelSMS {
+		return "otp"
+	}
+	return "otp_" + channel
+}
+
+type RateLimitPolicy struct {
+	Limit  int      `json:"limit"`
+	Window Duration `json:"window"`
+}
+
+// Duration is a time.Duration that reads and writes as a string like "2m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"2m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default SMS texts. The app hash and the "@domain #code" line are only added
+// for apps that set them; iOS needs the latter as the last line.
+const (
+	smsTemplateEN = "{{.AppName}}: your verification code is {{.Code}}. It expires in {{.ExpiryMinutes}} minutes." +
+		"{{if .AndroidHash}}\n{{.AndroidHash}}{{end}}{{if .Domain}}\n\n@{{.Domain}} #{{.Code}}{{end}}"
+	smsTemplateFA = "{{.AppName}}\nکد تایید شما: {{.Code}}\nاین کد تا {{.ExpiryMinutes}} دقیقه معتبر است." +
+		"{{if .AndroidHash}}\n{{.AndroidHash}}{{end}}{{if .Domain}}\n\n@{{.Domain}} #{{.Code}}{{end}}"
+)
+
+// Default voice scripts read the digits one by one, twice.
+const (
+	voiceTemplateEN = "Your {{.AppName}} verification code is {{spaced .Code}}. Again, your code is {{spaced .Code}}."
+	voiceTemplateFA = "کد تایید {{.AppName}} شما {{spaced .Code}} است. تکرار می‌کنم، {{spaced .Code}}."
+)
+
+// Default emails; the first line is the subject.
+const (
+	emailTemplateEN = "Your {{.AppName}} verification code\n" +
+		"Your {{.AppName}} verification code is {{.Code}}. It expires in {{.ExpiryMinutes}} minutes." +
+		"{{if .Link}}\n\nOr sign in directly with this link:\n{{.Link}}{{end}}" +
+		"\n\nIf you did not request this code, you can ignore this email."
+	emailTemplateFA = "کد تایید {{.AppName}}\n" +
+		"کد تایید {{.AppName}} شما: {{.Code}}\nاین کد تا {{.ExpiryMinutes}} دقیقه معتبر است." +
+		"{{if .Link}}\n\nیا با این پیوند مستقیما وارد شوید:\n{{.Link}}{{end}}" +
+		"\n\nاگر این کد را درخواست نکرده‌اید، این ایمیل را نادیده بگیرید."
+)
+
+// Default returns the settings used when no config file is present.
+func Default() *Config {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "my-secret-key" // use env var in real apps
+	}
+	return &Config{
+		Server: ServerConfig{
+			Mode:              ModeProduction,
+			Addr:              ":8080",
+			ReadTimeout:       Duration(10 * time.Second),
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			WriteTimeout:      Duration(15 * time.Second),
+			IdleTimeout:       Duration(60 * time.Second),
+			MaxHeaderBytes:    16 << 10,
+			ShutdownTimeout:   Duration(20 * time.Second),
+			ShutdownDelay:     0,
+			SweepInterval:     Duration(time.Minute),
+		},
+		API: APIConfig{
+			LegacyDeprecatedSince: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		},
+		Log: LogConfig{
+			Format: "json",
+			Level:  "info",
+			Redact: true,
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "dekamond-task",
+			SampleRatio: 1,
+		},
+		JWT: JWTConfig{
+			ActiveKey: "default",
+			Keys:      []JWTKey{{ID: "default", Secret: secret}},
+			TTL:       Duration(24 * time.Hour),
+		},
+		OTP: OTPConfig{
+			Length:       6,
+			TTL:          Duration(2 * time.Minute),
+			MaxAttempts:  5,
+			MagicLinkURL: "http://localhost:8080/v1/auth/magic",
+			DefaultApp:   "dekamond",
+			Apps:         []OTPApp{{ID: "dekamond", Name: "Dekamond"}},
+			Templates: []OTPTemplate{
+				{Channel: otptemplate.ChannelSMS, Locale: i18n.English, Text: smsTemplateEN},
+				{Channel: otptemplate.ChannelSMS, Locale: i18n.Persian, Text: smsTemplateFA},
+				{Channel: otptemplate.ChannelVoice, Locale: i18n.English, Text: voiceTemplateEN},
+				{Channel: otptemplate.ChannelVoice, Locale: i18n.Persian, Text: voiceTemplateFA},
+				{Channel: otptemplate.ChannelEmail, Locale: i18n.English, Text: emailTemplateEN},
+				{Channel: otptemplate.ChannelEmail, Locale: i18n.Persian, Text: emailTemplateFA},
+			},
+		},
+		MFA: MFAConfig{
+			Issuer:        "Dekamond",
+			ChallengeTTL:  Duration(5 * time.Minute),
+			MaxAttempts:   5,
+			RecoveryCodes: 10,
+		},
+		Passkey: PasskeyConfig{
+			RPID:       "localhost",
+			RPName:     "Dekamond",
+			Origins:    []string{"http://localhost:8080"},
+			Timeout:    Duration(2 * time.Minute),
+			MaxPending: 10000,
+		},
+		Session: SessionConfig{
+			MaxPerUser: 20,
+		},
+		Risk: RiskConfig{
+			Weights: map[string]int{
+				"new_device":        20,
+				"new_country":       30,
+				"new_asn":           10,
+				"impossible_travel": 50,
+				"rapid_requests":    30,
+			},
+			StepUpScore:     50,
+			BlockScore:      90,
+			TravelWindow:    Duration(time.Hour),
+			RapidRequests:   3,
+			RapidWindow:     Duration(2 * time.Minute),
+			Memory:          Duration(90 * 24 * time.Hour),
+			NotifyNewDevice: true,
+		},
+		Captcha: CaptchaConfig{
+			Mode:              captcha.ModeAdaptive,
+			Provider:          captcha.ProviderPoW,
+			VerifyTimeout:     Duration(5 * time.Second),
+			PoWDifficulty:     18,
+			PoWTTL:            Duration(2 * time.Minute),
+			KeyThreshold:      2,
+			PressureThreshold: 30,
+		},
+		Access: AccessConfig{
+			RulesFile: "access_rules.json",
+		},
+		Dispatch: DispatchConfig{
+			QueueSize:        1000,
+			Workers:          4,
+			MaxAttempts:      4,
+			InitialBackoff:   Duration(time.Second),
+			MaxBackoff:       Duration(15 * time.Second),
+			SendTimeout:      Duration(5 * time.Second),
+			StatusRetention:  Duration(15 * time.Minute),
+			HistoryRetention: Duration(7 * 24 * time.Hour),
+			HistorySize:      20,
+			BreakerThreshold: 5,
+			BreakerCooldown:  Duration(30 * time.Second),
+			Providers: []ProviderConfig{
+				{Name: "console", Type: "console", Channel: otptemplate.ChannelSMS},
+				{Name: "console-voice", Type: "console", Channel: otptemplate.ChannelVoice},
+				{Name: "console-email", Type: "console", Channel: otptemplate.ChannelEmail},
+			},
+		},
+		RateLimit: map[string]RateLimitPolicy{
+			"otp":       {Limit: 3, Window: Duration(10 * time.Minute)},
+			"otp_voice": {Limit: 2, Window: Duration(30 * time.Minute)},
+			"otp_email": {Limit: 5, Window: Duration(10 * time.Minute)},
+		},
+	}
+}
+
+// Load reads the JSON config at path on top of the defaults and validates it.
+// A missing file yields the defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate rejects settings that would leave the service unusable.
+func (c *Config) Validate() error {
+	switch c.Server.Mode {
+	case ModeProduction, ModeStaging, ModeDevelopment:
+	default:
+		return fmt.Errorf("server: unknown mode %q, want production, staging or development", c.Server.Mode)
+	}
+	if c.Server.Addr == "" {
+		return errors.New("server: addr is required")
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.ReadHeaderTimeout <= 0 || c.Server.WriteTimeout <= 0 ||
+		c.Server.IdleTimeout <= 0 || c.Server.ShutdownTimeout <= 0 || c.Server.SweepInterval <= 0 {
+		return errors.New("server: timeouts and sweep_interval must be positive")
+	}
+	if c.Server.ShutdownDelay < 0 {
+		return errors.New("server: shutdown_delay must not be negative")
+	}
+	if c.Server.MaxHeaderBytes < 1024 {
+		return errors.New("server: max_header_bytes must be at least 1024")
+	}
+	if t := c.Server.TLS; t.Enabled && (t.CertFile == "" || t.KeyFile == "") {
+		return errors.New("server: tls needs cert_file and key_file")
+	}
+
+	if c.API.LegacyDeprecatedSince.IsZero() {
+		return errors.New("api: legacy_deprecated_since is required")
+	}
+	if c.API.LegacySunset != nil && c.API.LegacySunset.Before(c.API.LegacyDeprecatedSince) {
+		return errors.New("api: legacy_sunset must not precede legacy_deprecated_since")
+	}
+
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		return errors.New(`log: format must be "json" or "text"`)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return fmt.Errorf("log: %w", err)
+	}
+
+	if c.Tracing.Enabled && (c.Tracing.Endpoint == "" || c.Tracing.ServiceName == "") {
+		return errors.New("tracing: endpoint and service_name are required")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return errors.New("tracing: sample_ratio must be between 0 and 1")
+	}
+
+	if len(c.JWT.Keys) == 0 {
+		return errors.New("jwt: at least one key is required")
+	}
+	active := false
+	seen := make(map[string]bool)
+	for _, k := range c.JWT.Keys {
+		if k.ID == "" || k.Secret == "" {
+			return errors.New("jwt: keys need an id and a secret")
+		}
+		if seen[k.ID] {
+			return fmt.Errorf("jwt: duplicate key id %q", k.ID)
+		}
+		seen[k.ID] = true
+		active = active || k.ID == c.JWT.ActiveKey
+	}
+	if !active {
+		return fmt.Errorf("jwt: active key %q not found", c.JWT.ActiveKey)
+	}
+	if c.JWT.TTL <= 0 {
+		return errors.New("jwt: ttl must be positive")
+	}
+
+	if c.OTP.Length < 4 || c.OTP.Length > 10 {
+		return errors.New("otp: length must be between 4 and 10")
+	}
+	if c.OTP.TTL <= 0 {
+		return errors.New("otp: ttl must be positive")
+	}
+	if c.OTP.MaxAttempts < 1 {
+		return errors.New("otp: max_attempts must be at least 1")
+	}
+	if c.OTP.MagicLinkURL != "" {
+		if u, err := url.Parse(c.OTP.MagicLinkURL); err != nil || u.Host == "" || u.RawQuery != "" {
+			return errors.New("otp: magic_link_url must be an absolute URL without a query")
+		}
+	}
+	if _, err := c.OTP.MessageTemplates(); err != nil {
+		return fmt.Errorf("otp: %w", err)
+	}
+	if len(c.OTP.TestNumbers) > 0 && c.Server.Mode == ModeProduction {
+		return errors.New("otp: test_numbers cannot be used in production mode")
+	}
+	testNumbers := make(map[string]bool)
+	for _, t := range c.OTP.TestNumbers {
+		if len(t.Phone) != 11 || !strings.HasPrefix(t.Phone, "09") || strings.Trim(t.Phone, "0123456789") != "" {
+			return fmt.Errorf("otp: test number %q is not a phone number (09XXXXXXXXX)", t.Phone)
+		}
+		if testNumbers[t.Phone] {
+			return fmt.Errorf("otp: duplicate test number %q", t.Phone)
+		}
+		testNumbers[t.Phone] = true
+		if len(t.Code) != c.OTP.Length || strings.Trim(t.Code, "0123456789") != "" {
+			return fmt.Errorf("otp: code of test number %q must be %d digits", t.Phone, c.OTP.Length)
+		}
+	}
+
+	if c.MFA.Issuer == "" || strings.Contains(c.MFA.Issuer, ":") {
+		return errors.New("mfa: issuer is required and must not contain ':'")
+	}
+	if c.MFA.ChallengeTTL <= 0 {
+		return errors.New("mfa: challenge_ttl must be positive")
+	}
+	if c.MFA.MaxAttempts < 1 {
+		return errors.New("mfa: max_attempts must be at least 1")
+	}
+	if c.MFA.RecoveryCodes < 1 || c.MFA.RecoveryCodes > 20 {
+		return errors.New("mfa: recovery_codes must be between 1 and 20")
+	}
+
+	if c.Passkey.RPID == "" || c.Passkey.RPName == "" || strings.ContainsAny(c.Passkey.RPID, ":/") {
+		return errors.New("passkey: rp_id must be a bare domain and rp_name is required")
+	}
+	if len(c.Passkey.Origins) == 0 {
+		return errors.New("passkey: at least one origin is required")
+	}
+	for _, o := range c.Passkey.Origins {
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Path != "" || u.RawQuery != "" {
+			return fmt.Errorf("passkey: origin %q must be a scheme and host without a path", o)
+		}
+		host := u.Hostname()
+		if host != c.Passkey.RPID && !strings.HasSuffix(host, "."+c.Passkey.RPID) {
+			return fmt.Errorf("passkey: origin %q is not on rp_id %q", o, c.Passkey.RPID)
+		}
+		if u.Scheme == "http" && host != "localhost" {
+			return fmt.Errorf("passkey: origin %q must use https", o)
+		}
+	}
+	if c.Passkey.Timeout <= 0 || c.Passkey.MaxPending < 1 {
+		return errors.New("passkey: timeout must be positive and max_pending at least 1")
+	}
+
+	if c.Session.MaxPerUser < 1 {
+		return errors.New("session: max_per_user must be at least 1")
+	}
+
+	for signal, w := range c.Risk.Weights {
+		if !slices.Contains(RiskSignals, signal) {
+			return fmt.Errorf("risk: unknown signal %q, want one of %s", signal, strings.Join(RiskSignals, ", "))
+		}
+		if w < 0 {
+			return fmt.Errorf("risk: weight of %s must not be negative", signal)
+		}
+	}
+	if c.Risk.StepUpScore < 1 || c.Risk.BlockScore < c.Risk.StepUpScore {
+		return errors.New("risk: step_up_score must be at least 1 and block_score at least step_up_score")
+	}
+	if c.Risk.TravelWindow < 0 || c.Risk.RapidWindow <= 0 || c.Risk.Memory <= 0 || c.Risk.RapidRequests < 1 {
+		return errors.New("risk: rapid_window and memory must be positive, travel_window not negative and rapid_requests at least 1")
+	}
+
+	cc := c.Captcha
+	switch cc.Mode {
+	case captcha.ModeOff, captcha.ModeAdaptive, captcha.ModeAlways:
+	default:
+		return fmt.Errorf("captcha: unknown mode %q, want off, adaptive or always", cc.Mode)
+	}
+	if _, hosted := captcha.HostedURLs[cc.Provider]; hosted {
+		if cc.Secret == "" || cc.SiteKey == "" {
+			return fmt.Errorf("captcha: provider %q needs a secret and a site_key", cc.Provider)
+		}
+		if cc.VerifyURL != "" {
+			if u, err := url.Parse(cc.VerifyURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return errors.New("captcha: verify_url must be an http(s) URL")
+			}
+		}
+		if cc.VerifyTimeout <= 0 {
+			return errors.New("captcha: verify_timeout must be positive")
+		}
+	} else if cc.Provider != captcha.ProviderPoW {
+		return fmt.Errorf("captcha: unknown provider %q, want pow, recaptcha, hcaptcha or turnstile", cc.Provider)
+	}
+	if cc.PoWDifficulty < 1 || cc.PoWDifficulty > 32 || cc.PoWTTL <= 0 {
+		return errors.New("captcha: pow_difficulty must be between 1 and 32 and pow_ttl positive")
+	}
+	if cc.KeyThreshold < 0 || cc.PressureThreshold < 0 {
+		return errors.New("captcha: key_threshold and pressure_threshold must not be negative")
+	}
+
+	if c.Access.RulesFile == "" {
+		return errors.New("access: rules_file is required")
+	}
+
+	d := c.Dispatch
+	if d.QueueSize < 1 || d.Workers < 1 || d.MaxAttempts < 1 || d.BreakerThreshold < 1 || d.HistorySize < 1 {
+		return errors.New("dispatch: queue_size, workers, max_attempts, breaker_threshold and history_size must be at least 1")
+	}
+	if d.InitialBackoff <= 0 || d.MaxBackoff < d.InitialBackoff || d.SendTimeout <= 0 ||
+		d.StatusRetention <= 0 || d.HistoryRetention < d.StatusRetention || d.BreakerCooldown <= 0 {
+		return errors.New("dispatch: durations must be positive, max_backoff at least initial_backoff " +
+			"and history_retention at least status_retention")
+	}
+	providers := make(map[string]bool)
+	for _, p := range d.Providers {
+		if p.Name == "" || p.Channel == "" {
+			return errors.New("dispatch: providers need a name and a channel")
+		}
+		if providers[p.Name] {
+			return fmt.Errorf("dispatch: duplicate provider %q", p.Name)
+		}
+		providers[p.Name] = true
+		switch p.Type {
+		case "console":
+		case "http", "voice":
+			if p.URL == "" {
+				return fmt.Errorf("dispatch: provider %q needs a url", p.Name)
+			}
+		case "smtp":
+			if _, _, err := net.SplitHostPort(p.Addr); err != nil {
+				return fmt.Errorf("dispatch: provider %q needs addr as host:port", p.Name)
+			}
+			if _, err := mail.ParseAddress(p.From); err != nil {
+				return fmt.Errorf("dispatch: provider %q needs a valid from address", p.Name)
+			}
+		default:
+			return fmt.Errorf("dispatch: provider %q has unknown type %q", p.Name, p.Type)
+		}
+	}
+	if !slices.ContainsFunc(d.Providers, func(p ProviderConfig) bool { return p.Channel == otptemplate.ChannelSMS }) {
+		return errors.New("dispatch: at least one sms provider is required")
+	}
+
+	if _, ok := c.RateLimit["otp"]; !ok {
+		return errors.New(`rate_limit: policy "otp" is required`)
+	}
+	for _, p := range d.Providers {
+		policy := OTPRateLimitPolicy(p.Channel)
+		if _, ok := c.RateLimit[policy]; !ok {
+			return fmt.Errorf("rate_limit: policy %q is required for provider %q", policy, p.Name)
+		}
+		if !slices.ContainsFunc(c.OTP.Templates, func(t OTPTemplate) bool {
+			return t.Channel == p.Channel && t.Locale == i18n.Default && t.App == ""
+		}) {
+			return fmt.Errorf("otp: provider %q needs a %s template for locale %q", p.Name, p.Channel, i18n.Default)
+		}
+	}
+	for name, p := range c.RateLimit {
+		if p.Limit < 1 || p.Window <= 0 {
+			return fmt.Errorf("rate_limit: policy %q needs a positive limit and window", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Watcher reloads the config file on SIGHUP or when it changes on disk.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	current  atomic.Pointer[Config]
+	log      *slog.Logger
+
+	mu       sync.Mutex
+	modTime  time.Time
+	onChange []func(*Config) error
+}
+
+// NewWatcher creates a Watcher serving initial until the first reload.
+func NewWatcher(path string, initial *Config, log *slog.Logger) *Watcher {
+	w := &Watcher{path: path, interval: 5 * time.Second, log: log}
+	w.current.Store(initial)
+	if fi, err := os.Stat(path); err == nil {
+		w.modTime = fi.ModTime()
+	}
+	return w
+}
+
+// Current returns the live config.
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// OnChange registers fn to be called with every config that passes validation.
+// fn applies the config or, returning an error, rejects the reload; it must
+// then leave everything as it was.
+func (w *Watcher) OnChange(fn func(*Config) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// Reload loads the file again; on error the previous config stays live.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if fi, err := os.Stat(w.path); err == nil {
+		w.modTime = fi.ModTime()
+	}
+	cfg, err := Load(w.path)
+	if err != nil {
+		return err
+	}
+	for _, fn := range w.onChange {
+		if err := fn(cfg); err != nil {
+			return err
+		}
+	}
+	w.current.Store(cfg)
+	return nil
+}
+
+// Run blocks until ctx is done, reloading on SIGHUP and on file modification.
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.reload("SIGHUP")
+		case <-ticker.C:
+			if w.changed() {
+				w.reload("file change")
+			}
+		}
+	}
+}
+
+func (w *Watcher) changed() bool {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !fi.ModTime().Equal(w.modTime)
+}
+
+func (w *Watcher) reload(reason string) {
+	if err := w.Reload(); err != nil {
+		w.log.Error("config reload rejected, keeping previous config", "trigger", reason, "err", err)
+		return
+	}
+	w.log.Info("config reloaded", "trigger", reason)
+}
@@ -0,0 +1,83 @@
+package dispatch
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a per-provider circuit breaker. After threshold consecutive
+// failures it opens and rejects sends for cooldown, then lets a single probe
+// through (half-open); the probe's result closes or re-opens it.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether a send may go through.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// failure records a failed send; the threshold-th in a row opens the
+// breaker, as does a failed probe.
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// Circuit states reported by Dispatcher.Circuits.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// state reports the circuit state; half-open once the cooldown is over, until
+// a probe closes or re-opens it.
+func (b *breaker) state() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.failures < b.threshold:
+		return CircuitClosed
+	case b.probing || time.Since(b.openedAt) >= b.cooldown:
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
+
+// open reports whether sends are currently being rejected.
+func (b *breaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
@@ -0,0 +1,464 @@
+package dispatch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	mrand "math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"dekamond-task/package/logger"
+	"dekamond-task/package/metrics"
+	"dekamond-task/package/schedule"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("dekamond-task/package/dispatch")
+
+var (
+	ErrQueueFull        = errors.New("OTP delivery queue is full")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	ErrNoProvider       = errors.New("this delivery channel is not available")
+
+	errUnavailable = errors.New("every provider is unavailable")
+	errExpired     = errors.New("code expired before it could be sent")
+	errShutdown    = errors.New("server shutting down")
+)
+
+// Delivery statuses. Sent and failed are final for the dispatcher; a
+// delivery report from the provider can later turn sent into delivered or
+// undelivered.
+const (
+	StatusQueued      = "queued"
+	StatusSending     = "sending"
+	StatusRetrying    = "retrying"
+	StatusSent        = "sent"
+	StatusFailed      = "failed"
+	StatusDelivered   = "delivered"
+	StatusUndelivered = "undelivered"
+)
+
+// Message is one OTP text to deliver. Retries stop once ExpiresAt passes.
+type Message struct {
+	ID        string
+	Channel   string
+	To        string
+	Body      string
+	Locale    string // language of Body, for text-to-speech
+	ExpiresAt time.Time
+}
+
+// Delivery is the state of a queued message.
+type Delivery struct {
+	ID                string
+	Channel           string
+	To                string
+	Status            string
+	Provider          string
+	ProviderMessageID string
+	Attempts          int
+	Error             string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	ReportedAt        time.Time // when the provider's delivery report arrived
+
+	done chan struct{} // closed once Status is final
+}
+
+func (d *Delivery) final() bool {
+	return d.Status != StatusQueued && d.Status != StatusSending && d.Status != StatusRetrying
+}
+
+// Options sizes the queue and tunes retries. Attempt n waits about
+// InitialBackoff*2^(n-2), capped at MaxBackoff.
+type Options struct {
+	QueueSize        int
+	Workers          int
+	MaxAttempts      int
+	InitialBackoff   time.Duration
+	MaxBackoff       time.Duration
+	SendTimeout      time.Duration
+	Retention        time.Duration // how long final statuses stay pollable
+	HistoryRetention time.Duration // how long deliveries stay in the per-recipient history
+	HistorySize      int           // deliveries kept per recipient
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+type route struct {
+	provider Provider
+	breaker  *breaker
+}
+
+// recordCircuit exports the state of the route's breaker.
+func (rt route) recordCircuit() {
+	open := 0.0
+	if rt.breaker.open() {
+		open = 1
+	}
+	metrics.ProviderCircuitOpen.WithLabelValues(rt.provider.Name()).Set(open)
+}
+
+type job struct {
+	msg  Message
+	log  *slog.Logger
+	link trace.Link
+}
+
+// Dispatcher sends messages from a bounded queue on a pool of workers, trying
+// each channel's providers in order and retrying with exponential backoff.
+type Dispatcher struct {
+	opts   Options
+	log    *slog.Logger
+	routes map[string][]route // channel -> providers in failover order
+	queue  chan job
+
+	mu           sync.Mutex
+	deliveries   map[string]*Delivery   // delivery ID -> state
+	history      map[string][]*Delivery // recipient -> deliveries, oldest first
+	byProviderID map[string]*Delivery   // provider:provider message ID -> delivery
+}
+
+func NewDispatcher(opts Options, log *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		opts:         opts,
+		log:          log,
+		routes:       make(map[string][]route),
+		queue:        make(chan job, opts.QueueSize),
+		deliveries:   make(map[string]*Delivery),
+		history:      make(map[string][]*Delivery),
+		byProviderID: make(map[string]*Delivery),
+	}
+}
+
+// AddProvider appends p to the failover order of channel. Call it before Run.
+func (d *Dispatcher) AddProvider(channel string, p Provider) {
+	d.routes[channel] = append(d.routes[channel], route{
+		provider: p,
+		breaker:  newBreaker(d.opts.BreakerThreshold, d.opts.BreakerCooldown),
+	})
+	metrics.ProviderCircuitOpen.WithLabelValues(p.Name()).Set(0)
+}
+
+// Serves reports whether channel has any provider.
+func (d *Dispatcher) Serves(channel string) bool {
+	return len(d.routes[channel]) > 0
+}
+
+// Circuits returns the state of each provider's circuit breaker, keyed by
+// provider name: CircuitClosed, CircuitOpen or CircuitHalfOpen.
+func (d *Dispatcher) Circuits() map[string]string {
+	circuits := make(map[string]string)
+	for _, routes := range d.routes {
+		for _, rt := range routes {
+			circuits[rt.provider.Name()] = rt.breaker.state()
+		}
+	}
+	return circuits
+}
+
+// Enqueue queues m without blocking and returns its delivery ID.
+func (d *Dispatcher) Enqueue(ctx context.Context, m Message) (string, error) {
+	if !d.Serves(m.Channel) {
+		return "", ErrNoProvider
+	}
+	m.ID = newID()
+	now := time.Now()
+	dl := &Delivery{
+		ID:        m.ID,
+		Channel:   m.Channel,
+		To:        m.To,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		done:      make(chan struct{}),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case d.queue <- job{msg: m, log: logger.FromContext(ctx, d.log), link: trace.LinkFromContext(ctx)}:
+		d.deliveries[m.ID] = dl
+		h := append(d.history[m.To], dl)
+		if len(h) > d.opts.HistorySize {
+			h = h[len(h)-d.opts.HistorySize:]
+		}
+		d.history[m.To] = h
+		return m.ID, nil
+	default:
+		return "", ErrQueueFull
+	}
+}
+
+// Status returns a snapshot of the delivery.
+func (d *Dispatcher) Status(id string) (Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dl, ok := d.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return *dl, nil
+}
+
+// Wait blocks until the delivery is final or ctx is done, then returns its
+// latest state.
+func (d *Dispatcher) Wait(ctx context.Context, id string) (Delivery, error) {
+	d.mu.Lock()
+	dl, ok := d.deliveries[id]
+	d.mu.Unlock()
+	if !ok {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	select {
+	case <-dl.done:
+	case <-ctx.Done():
+	}
+	return d.Status(id)
+}
+
+// History returns the deliveries to a phone or email, newest first.
+func (d *Dispatcher) History(to string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h := d.history[to]
+	out := make([]Delivery, len(h))
+	for i, dl := range h {
+		out[len(h)-1-i] = *dl
+	}
+	return out
+}
+
+// Report records a provider's delivery report for the message it accepted as
+// providerID. Reports for messages that are gone from the history are
+// ErrDeliveryNotFound.
+func (d *Dispatcher) Report(provider, providerID string, delivered bool, detail string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dl, ok := d.byProviderID[provider+":"+providerID]
+	if !ok {
+		return ErrDeliveryNotFound
+	}
+	status := StatusUndelivered
+	if delivered {
+		status = StatusDelivered
+	}
+	dl.Status = status
+	dl.Error = detail
+	dl.ReportedAt = time.Now()
+	dl.UpdatedAt = dl.ReportedAt
+	metrics.OTPDeliveryReports.WithLabelValues(provider, status).Inc()
+	return nil
+}
+
+// Len returns the number of queued messages.
+func (d *Dispatcher) Len() int {
+	return len(d.queue)
+}
+
+// Run delivers queued messages until ctx is done. Sends in flight finish;
+// messages still queued are marked failed.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range d.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-d.queue:
+					d.deliver(ctx, j)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for {
+		select {
+		case j := <-d.queue:
+			d.finish(j.msg, StatusFailed, "", "", errShutdown)
+		default:
+			return
+		}
+	}
+}
+
+// RunSweeper drops final deliveries older than the retention every interval
+// until ctx is done.
+func (d *Dispatcher) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, d.sweep)
+}
+
+func (d *Dispatcher) sweep() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	cutoff := now.Add(-d.opts.Retention)
+	for id, dl := range d.deliveries {
+		if dl.final() && dl.UpdatedAt.Before(cutoff) {
+			delete(d.deliveries, id)
+		}
+	}
+
+	cutoff = now.Add(-d.opts.HistoryRetention)
+	for to, h := range d.history {
+		h = slices.DeleteFunc(h, func(dl *Delivery) bool { return dl.final() && dl.UpdatedAt.Before(cutoff) })
+		if len(h) == 0 {
+			delete(d.history, to)
+		} else {
+			d.history[to] = h
+		}
+	}
+	for key, dl := range d.byProviderID {
+		if dl.UpdatedAt.Before(cutoff) {
+			delete(d.byProviderID, key)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, j job) {
+	m := j.msg
+	ctx, span := tracer.Start(ctx, "Dispatcher.deliver", trace.WithLinks(j.link),
+		trace.WithAttributes(attribute.String("otp.channel", m.Channel)))
+	defer span.End()
+	log := j.log.With("delivery_id", m.ID)
+	// In-flight sends outlive shutdown; only the waits between attempts are cut short
+	sendCtx := logger.WithLogger(context.WithoutCancel(ctx), log)
+
+	routes := d.routes[m.Channel]
+	rejected := make([]bool, len(routes)) // providers that failed permanently
+	var lastErr error
+attempts:
+	for attempt := 1; attempt <= d.opts.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			wait := d.backoff(attempt)
+			if time.Now().Add(wait).After(m.ExpiresAt) {
+				lastErr = errExpired
+				break
+			}
+			d.update(m.ID, StatusRetrying, attempt-1)
+			select {
+			case <-ctx.Done():
+				lastErr = errShutdown
+				break attempts
+			case <-time.After(wait):
+			}
+		}
+		d.update(m.ID, StatusSending, attempt)
+
+		tried := false
+		for i, rt := range routes {
+			if rejected[i] || !rt.breaker.allow() {
+				continue
+			}
+			tried = true
+			name := rt.provider.Name()
+			attemptCtx, cancel := context.WithTimeout(sendCtx, d.opts.SendTimeout)
+			providerID, err := rt.provider.Send(attemptCtx, m)
+			cancel()
+			if err == nil {
+				rt.breaker.success()
+				rt.recordCircuit()
+				metrics.OTPSendAttempts.WithLabelValues(name, "ok").Inc()
+				d.finish(m, StatusSent, name, providerID, nil)
+				log.Info("OTP delivered to provider", "provider", name, "attempt", attempt)
+				return
+			}
+			lastErr = err
+			metrics.OTPSendAttempts.WithLabelValues(name, "error").Inc()
+			log.Warn("OTP send failed", "provider", name, "attempt", attempt, "err", err)
+			if errors.Is(err, ErrPermanent) {
+				// The provider is fine, which also ends a half-open probe; the
+				// message is what it refused
+				rt.breaker.success()
+				rt.recordCircuit()
+				rejected[i] = true
+				continue
+			}
+			rt.breaker.failure()
+			rt.recordCircuit()
+		}
+		if !tried && lastErr == nil {
+			lastErr = errUnavailable
+		}
+		if allTrue(rejected) {
+			break
+		}
+	}
+
+	span.SetStatus(codes.Error, lastErr.Error())
+	d.finish(m, StatusFailed, "", "", lastErr)
+	log.Error("OTP delivery failed", "to", m.To, "err", lastErr)
+}
+
+// backoff returns the wait before attempt (2 or later), with jitter.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.InitialBackoff << (attempt - 2)
+	if wait <= 0 || wait > d.opts.MaxBackoff {
+		wait = d.opts.MaxBackoff
+	}
+	return wait/2 + mrand.N(wait/2+1)
+}
+
+func (d *Dispatcher) update(id, status string, attempts int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dl, ok := d.deliveries[id]; ok {
+		dl.Status = status
+		dl.Attempts = attempts
+		dl.UpdatedAt = time.Now()
+	}
+}
+
+func (d *Dispatcher) finish(m Message, status, provider, providerID string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dl, ok := d.deliveries[m.ID]
+	if !ok {
+		return
+	}
+	dl.Status = status
+	dl.Provider = provider
+	dl.ProviderMessageID = providerID
+	dl.UpdatedAt = time.Now()
+	if err != nil {
+		dl.Error = err.Error()
+	}
+	if providerID != "" {
+		d.byProviderID[provider+":"+providerID] = dl
+	}
+	close(dl.done)
+
+	if status == StatusSent {
+		metrics.OTPSent.WithLabelValues(m.Channel).Inc()
+		metrics.OTPDispatchLatency.WithLabelValues(m.Channel).Observe(dl.UpdatedAt.Sub(dl.CreatedAt).Seconds())
+	} else {
+		metrics.OTPSendFailed.WithLabelValues(m.Channel).Inc()
+	}
+}
+
+func allTrue(bs []bool) bool {
+	for _, b := range bs {
+		if !b {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,193 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("gateway timeout")
+
+// fakeProvider fails its sends with results in order, then accepts them.
+type fakeProvider struct {
+	name string
+
+	mu      sync.Mutex
+	results []error
+	calls   int
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Send(_ context.Context, m Message) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if len(p.results) > 0 {
+		err := p.results[0]
+		p.results = p.results[1:]
+		if err != nil {
+			return "", err
+		}
+	}
+	return p.name + "-" + m.ID, nil
+}
+
+func testOptions() Options {
+	return Options{
+		QueueSize:        10,
+		Workers:          1,
+		MaxAttempts:      3,
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       2 * time.Millisecond,
+		SendTimeout:      time.Second,
+		Retention:        time.Minute,
+		HistoryRetention: time.Minute,
+		HistorySize:      10,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+func newTestDispatcher(opts Options, providers ...*fakeProvider) *Dispatcher {
+	d := NewDispatcher(opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, p := range providers {
+		d.AddProvider("sms", p)
+	}
+	return d
+}
+
+// deliverNext queues a message and delivers it on the calling goroutine.
+func deliverNext(t *testing.T, d *Dispatcher, ttl time.Duration) Delivery {
+	t.Helper()
+	id, err := d.Enqueue(context.Background(), Message{Channel: "sms", To: "09123456789", Body: "code", ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	d.deliver(context.Background(), <-d.queue)
+	dl, err := d.Status(id)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	return dl
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		first        []error
+		second       []error
+		ttl          time.Duration
+		wantStatus   string
+		wantProvider string
+		wantAttempts int
+		wantCalls    [2]int
+	}{
+		{"first provider accepts", nil, nil, time.Minute, StatusSent, "a", 1, [2]int{1, 0}},
+		{"transient error fails over", []error{errTransient}, nil, time.Minute, StatusSent, "b", 1, [2]int{1, 1}},
+		{"transient errors are retried", []error{errTransient}, []error{errTransient}, time.Minute, StatusSent, "a", 2, [2]int{2, 1}},
+		{"permanent error fails over", []error{ErrPermanent}, nil, time.Minute, StatusSent, "b", 1, [2]int{1, 1}},
+		{"permanent everywhere is not retried", []error{ErrPermanent}, []error{ErrPermanent}, time.Minute, StatusFailed, "", 1, [2]int{1, 1}},
+		{"attempts run out", []error{errTransient, errTransient, errTransient}, []error{errTransient, errTransient, errTransient}, time.Minute, StatusFailed, "", 3, [2]int{3, 3}},
+		{"no retry past expiry", []error{errTransient}, []error{errTransient}, time.Nanosecond, StatusFailed, "", 1, [2]int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &fakeProvider{name: "a", results: tt.first}
+			b := &fakeProvider{name: "b", results: tt.second}
+			dl := deliverNext(t, newTestDispatcher(testOptions(), a, b), tt.ttl)
+			if dl.Status != tt.wantStatus || dl.Provider != tt.wantProvider || dl.Attempts != tt.wantAttempts {
+				t.Errorf("got status %s, provider %q, attempts %d; want %s, %q, %d (error %q)",
+					dl.Status, dl.Provider, dl.Attempts, tt.wantStatus, tt.wantProvider, tt.wantAttempts, dl.Error)
+			}
+			if calls := [2]int{a.calls, b.calls}; calls != tt.wantCalls {
+				t.Errorf("got calls %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBreakerOpensAndSkipsProvider(t *testing.T) {
+	opts := testOptions()
+	opts.MaxAttempts, opts.BreakerThreshold = 1, 2
+	a := &fakeProvider{name: "a", results: []error{errTransient, errTransient}}
+	b := &fakeProvider{name: "b"}
+	d := newTestDispatcher(opts, a, b)
+
+	for range 3 {
+		deliverNext(t, d, time.Minute)
+	}
+	if a.calls != 2 {
+		t.Errorf("open breaker let %d sends through to a, want 2", a.calls)
+	}
+	if got := d.Circuits(); got["a"] != CircuitOpen || got["b"] != CircuitClosed {
+		t.Errorf("Circuits() = %v, want a open and b closed", got)
+	}
+}
+
+// A permanent error answered to the half-open probe must release it, or the
+// provider stays out of the rotation for good.
+func TestHalfOpenProbeEndsOnPermanentError(t *testing.T) {
+	opts := testOptions()
+	opts.MaxAttempts, opts.BreakerThreshold, opts.BreakerCooldown = 1, 1, 10*time.Millisecond
+	a := &fakeProvider{name: "a", results: []error{errTransient, ErrPermanent}}
+	b := &fakeProvider{name: "b"}
+	d := newTestDispatcher(opts, a, b)
+
+	if dl := deliverNext(t, d, time.Minute); dl.Provider != "b" {
+		t.Fatalf("first message sent by %q, want b after a failed", dl.Provider)
+	}
+	time.Sleep(2 * opts.BreakerCooldown)
+	if dl := deliverNext(t, d, time.Minute); dl.Provider != "b" || a.calls != 2 {
+		t.Fatalf("probe: sent by %q with %d calls to a, want b and 2", dl.Provider, a.calls)
+	}
+	if dl := deliverNext(t, d, time.Minute); dl.Provider != "a" {
+		t.Errorf("after a permanent error on the probe, sent by %q, want a", dl.Provider)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps func(b *breaker)
+		want  bool // allow after steps
+	}{
+		{"closed", func(b *breaker) {}, true},
+		{"below threshold", func(b *breaker) { b.failure() }, true},
+		{"open", func(b *breaker) { b.failure(); b.failure() }, false},
+		{"half-open lets one probe", func(b *breaker) { b.failure(); b.failure(); time.Sleep(2 * time.Millisecond) }, true},
+		{"only one probe at a time", func(b *breaker) {
+			b.failure()
+			b.failure()
+			time.Sleep(2 * time.Millisecond)
+			b.allow()
+		}, false},
+		{"failed probe reopens", func(b *breaker) {
+			b.failure()
+			b.failure()
+			time.Sleep(2 * time.Millisecond)
+			b.allow()
+			b.failure()
+		}, false},
+		{"successful probe closes", func(b *breaker) {
+			b.failure()
+			b.failure()
+			time.Sleep(2 * time.Millisecond)
+			b.allow()
+			b.success()
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(2, time.Millisecond)
+			tt.steps(b)
+			if got := b.allow(); got != tt.want {
+				t.Errorf("allow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
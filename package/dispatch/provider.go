@@ -0,0 +1,243 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"dekamond-task/package/logger"
+	"dekamond-task/package/tracing"
+)
+
+// ErrPermanent marks a send that will fail again if retried, such as a
+// rejected number. The dispatcher moves on to the next provider.
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// Provider sends messages over one channel and returns its own message ID.
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, m Message) (string, error)
+}
+
+// ConsoleProvider logs messages instead of sending them; for development.
+// It serves any channel.
+type ConsoleProvider struct {
+	name string
+	log  *slog.Logger
+}
+
+func NewConsoleProvider(name string, log *slog.Logger) *ConsoleProvider {
+	return &ConsoleProvider{name: name, log: log}
+}
+
+func (p *ConsoleProvider) Name() string { return p.name }
+
+func (p *ConsoleProvider) Send(ctx context.Context, m Message) (string, error) {
+	logger.FromContext(ctx, p.log).Info("OTP message", "provider", p.name, "channel", m.Channel, "to", m.To, "body", m.Body)
+	return "console-" + m.ID, nil
+}
+
+// HTTPProvider posts messages as JSON to an SMS gateway:
+//
+//	POST <url> {"to": "...", "text": "...", "reference": "<delivery id>"}
+//	200 {"message_id": "..."}
+//
+// 429 and 5xx responses are retried; other 4xx responses are permanent.
+type HTTPProvider struct {
+	name   string
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewHTTPProvider(name, url, apiKey string, timeout time.Duration) *HTTPProvider {
+	return &HTTPProvider{name: name, url: url, apiKey: apiKey, client: tracing.HTTPClient(timeout)}
+}
+
+func (p *HTTPProvider) Name() string { return p.name }
+
+func (p *HTTPProvider) Send(ctx context.Context, m Message) (string, error) {
+	return p.post(ctx, map[string]string{"to": m.To, "text": m.Body, "reference": m.ID})
+}
+
+// post sends payload as JSON and returns the message ID from the response.
+func (p *HTTPProvider) post(ctx context.Context, payload any) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("%s: status %d: %s", p.name, resp.StatusCode, bytes.TrimSpace(msg))
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			err = fmt.Errorf("%w: %w", ErrPermanent, err)
+		}
+		return "", err
+	}
+	var out struct {
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<10)).Decode(&out); err != nil {
+		return "", fmt.Errorf("%s: decode response: %w", p.name, err)
+	}
+	return out.MessageID, nil
+}
+
+// VoiceProvider places a call through a text-to-speech API that reads the
+// message out. It speaks the same HTTP conventions as HTTPProvider:
+//
+//	POST <url> {"to": "...", "speech": {"text": "...", "language": "fa"}, "reference": "<delivery id>"}
+//	200 {"message_id": "..."}
+type VoiceProvider struct {
+	*HTTPProvider
+}
+
+func NewVoiceProvider(name, url, apiKey string, timeout time.Duration) *VoiceProvider {
+	return &VoiceProvider{NewHTTPProvider(name, url, apiKey, timeout)}
+}
+
+func (p *VoiceProvider) Send(ctx context.Context, m Message) (string, error) {
+	type speech struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+	}
+	return p.post(ctx, struct {
+		To        string `json:"to"`
+		Speech    speech `json:"speech"`
+		Reference string `json:"reference"`
+	}{m.To, speech{m.Body, m.Locale}, m.ID})
+}
+
+// SMTPProvider sends email over SMTP, upgrading to TLS when the server offers
+// STARTTLS. The first line of the message is the subject. 4xx replies are
+// retried, 5xx replies are permanent.
+type SMTPProvider struct {
+	name     string
+	addr     string // host:port
+	from     *mail.Address
+	username string
+	password string
+	log      *slog.Logger
+}
+
+// NewSMTPProvider sends from, an address like "Dekamond <no-reply@example.com>".
+func NewSMTPProvider(name, addr, from, username, password string, log *slog.Logger) (*SMTPProvider, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("%s: from: %w", name, err)
+	}
+	return &SMTPProvider{name: name, addr: addr, from: sender, username: username, password: password, log: log}, nil
+}
+
+func (p *SMTPProvider) Name() string { return p.name }
+
+func (p *SMTPProvider) Send(ctx context.Context, m Message) (string, error) {
+	host, _, err := net.SplitHostPort(p.addr)
+	if err != nil {
+		return "", err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return "", err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return "", err
+		}
+	}
+	if p.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", p.username, p.password, host)); err != nil {
+			return "", smtpError(err)
+		}
+	}
+	if err := c.Mail(p.from.Address); err != nil {
+		return "", smtpError(err)
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return "", smtpError(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return "", smtpError(err)
+	}
+	_, domain, _ := strings.Cut(p.from.Address, "@")
+	id := fmt.Sprintf("<%s@%s>", m.ID, domain)
+	if _, err := w.Write(p.compose(id, m)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", smtpError(err)
+	}
+	// The server has taken the message; a failed QUIT must not get it sent
+	// again.
+	if err := c.Quit(); err != nil {
+		logger.FromContext(ctx, p.log).Warn("SMTP QUIT failed after the message was accepted", "provider", p.name, "message_id", id, "err", err)
+	}
+	return id, nil
+}
+
+// compose builds a UTF-8 plain-text email whose subject is the first line of
+// the message body.
+func (p *SMTPProvider) compose(id string, m Message) []byte {
+	subject, body, _ := strings.Cut(m.Body, "\n")
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", p.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", id)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	_, _ = qp.Write([]byte(strings.TrimLeft(body, "\n")))
+	_ = qp.Close()
+	return b.Bytes()
+}
+
+func smtpError(err error) error {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) && tpErr.Code >= 500 {
+		return fmt.Errorf("%w: %w", ErrPermanent, err)
+	}
+	return err
+}
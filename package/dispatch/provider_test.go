@@ -0,0 +1,185 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// voiceGateway stands in for a text-to-speech call API, answering every call
+// with status and body after recording the request.
+func voiceGateway(t *testing.T, status int, body string, got *map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q, want the API key", r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestVoiceProviderSend(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantID        string
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{"accepted", http.StatusOK, `{"message_id":"call-1"}`, "call-1", false, false},
+		{"queued", http.StatusAccepted, `{"message_id":"call-2"}`, "call-2", false, false},
+		{"throttled is retried", http.StatusTooManyRequests, `slow down`, "", true, false},
+		{"server error is retried", http.StatusBadGateway, ``, "", true, false},
+		{"rejected number is permanent", http.StatusBadRequest, `{"error":"invalid number"}`, "", true, true},
+		{"garbled response", http.StatusOK, `not json`, "", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			srv := voiceGateway(t, tt.status, tt.body, &got)
+			defer srv.Close()
+
+			p := NewVoiceProvider("voice", srv.URL, "secret", time.Second)
+			id, err := p.Send(context.Background(), Message{ID: "d1", Channel: "voice", To: "09123456789", Body: "Your code is 1 2 3 4 5", Locale: "fa"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrPermanent) != tt.wantPermanent {
+				t.Errorf("Send() error = %v, want permanent %v", err, tt.wantPermanent)
+			}
+			if id != tt.wantID {
+				t.Errorf("Send() id = %q, want %q", id, tt.wantID)
+			}
+
+			speech, _ := got["speech"].(map[string]any)
+			if got["to"] != "09123456789" || got["reference"] != "d1" ||
+				speech["text"] != "Your code is 1 2 3 4 5" || speech["language"] != "fa" {
+				t.Errorf("gateway got %v", got)
+			}
+		})
+	}
+}
+
+// smtpServer is a minimal SMTP server answering RCPT TO with rcptReply. With
+// dropQuit it hangs up instead of answering QUIT.
+type smtpServer struct {
+	ln        net.Listener
+	rcptReply string
+	dropQuit  bool
+	data      chan string
+}
+
+func newSMTPServer(t *testing.T, rcptReply string, dropQuit bool) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln, rcptReply: rcptReply, dropQuit: dropQuit, data: make(chan string, 1)}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(textproto.NewConn(conn))
+	}
+}
+
+func (s *smtpServer) session(c *textproto.Conn) {
+	defer c.Close()
+	_ = c.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		switch cmd {
+		case "EHLO", "HELO", "MAIL":
+			_ = c.PrintfLine("250 OK")
+		case "RCPT":
+			_ = c.PrintfLine("%s", s.rcptReply)
+		case "DATA":
+			_ = c.PrintfLine("354 go ahead")
+			b, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data <- string(b)
+			_ = c.PrintfLine("250 queued")
+		case "QUIT":
+			if !s.dropQuit {
+				_ = c.PrintfLine("221 bye")
+			}
+			return
+		default:
+			_ = c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPProviderSend(t *testing.T) {
+	tests := []struct {
+		name          string
+		rcptReply     string
+		dropQuit      bool
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{"accepted", "250 OK", false, false, false},
+		{"accepted without a QUIT reply", "250 OK", true, false, false},
+		{"mailbox busy is retried", "451 try again later", false, true, false},
+		{"unknown mailbox is permanent", "550 no such user", false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSMTPServer(t, tt.rcptReply, tt.dropQuit)
+			p, err := NewSMTPProvider("smtp", srv.ln.Addr().String(), "Dekamond <no-reply@example.com>", "", "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatalf("NewSMTPProvider: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			id, err := p.Send(ctx, Message{ID: "d1", Channel: "email", To: "user@example.org", Body: "Your code\nYour code is 12345"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrPermanent) != tt.wantPermanent {
+				t.Errorf("Send() error = %v, want permanent %v", err, tt.wantPermanent)
+			}
+			if tt.wantErr {
+				return
+			}
+			if id != "<d1@example.com>" {
+				t.Errorf("Send() id = %q, want <d1@example.com>", id)
+			}
+			msg := <-srv.data
+			for _, want := range []string{"To: user@example.org\n", "Subject: Your code\n", "Message-ID: <d1@example.com>\n", "Your code is 12345"} {
+				if !strings.Contains(msg, want) {
+					t.Errorf("message lacks %q:\n%s", want, msg)
+				}
+			}
+		})
+	}
+}
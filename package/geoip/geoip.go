@@ -0,0 +1,109 @@
+package geoip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Location is where an address is routed from.
+type Location struct {
+	Country string // ISO 3166 alpha-2
+	ASN     uint32
+	Org     string // AS description
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	loc        Location
+}
+
+// DB maps address ranges to locations. It is read-only once loaded.
+type DB struct {
+	ranges []ipRange // sorted by start, not overlapping
+}
+
+// Open loads an ip2asn database file (iptoasn.com's ip2asn-combined.tsv,
+// IPv4 and IPv6): one tab-separated range per line with the first and last
+// address, AS number, country code and AS description.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Parse reads the format Open loads. Unrouted ranges (AS 0) are skipped.
+func Parse(r io.Reader) (*DB, error) {
+	db := &DB{}
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		f := strings.SplitN(text, "\t", 5)
+		if len(f) < 4 {
+			return nil, fmt.Errorf("line %d: want at least 4 tab-separated fields", line)
+		}
+		start, err1 := netip.ParseAddr(f[0])
+		end, err2 := netip.ParseAddr(f[1])
+		asn, err3 := strconv.ParseUint(f[2], 10, 32)
+		if err1 != nil || err2 != nil || err3 != nil || start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("line %d: bad range", line)
+		}
+		if asn == 0 {
+			continue
+		}
+		loc := Location{Country: strings.ToUpper(f[3]), ASN: uint32(asn)}
+		if len(f) == 5 {
+			loc.Org = f[4]
+		}
+		db.ranges = append(db.ranges, ipRange{start: start, end: end, loc: loc})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(db.ranges, func(a, b ipRange) int { return a.start.Compare(b.start) })
+	for i := 1; i < len(db.ranges); i++ {
+		if !db.ranges[i-1].end.Less(db.ranges[i].start) {
+			return nil, fmt.Errorf("range %s overlaps %s", db.ranges[i].start, db.ranges[i-1].start)
+		}
+	}
+	return db, nil
+}
+
+// Len reports how many ranges are loaded.
+func (db *DB) Len() int { return len(db.ranges) }
+
+// Lookup finds the location of ip. IPv4-mapped IPv6 addresses are looked
+// up as IPv4.
+func (db *DB) Lookup(ip netip.Addr) (Location, bool) {
+	ip = ip.Unmap()
+	// First range starting after ip; the one before it may contain ip
+	i, _ := slices.BinarySearchFunc(db.ranges, ip, func(r ipRange, ip netip.Addr) int {
+		if r.start.Compare(ip) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	if i == 0 {
+		return Location{}, false
+	}
+	r := db.ranges[i-1]
+	if r.start.Is4() != ip.Is4() || r.end.Less(ip) {
+		return Location{}, false
+	}
+	return r.loc, true
+}
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckFunc reports whether a dependency is usable; it must honour ctx.
+type CheckFunc func(ctx context.Context) error
+
+// Health aggregates dependency checks for the readiness probe.
+type Health struct {
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]CheckFunc
+	info   map[string]func() any
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status    string  `json:"status" example:"ok"`
+	LatencyMs float64 `json:"latency_ms" example:"0.12"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness summary; Status is "ok" only if every check passed.
+// Info carries state worth seeing that does not decide readiness.
+type Report struct {
+	Status string                 `json:"status" example:"ok"`
+	Checks map[string]CheckResult `json:"checks"`
+	Info   map[string]any         `json:"info,omitempty"`
+}
+
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// New creates a Health whose checks each get timeout to complete.
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout, checks: make(map[string]CheckFunc), info: make(map[string]func() any)}
+}
+
+// Register adds a named check; registering a name again replaces it.
+func (h *Health) Register(name string, fn CheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = fn
+}
+
+// RegisterInfo adds named detail to every report without affecting its
+// status; registering a name again replaces it.
+func (h *Health) RegisterInfo(name string, fn func() any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.info[name] = fn
+}
+
+// SetShuttingDown makes every later readiness check fail.
+func (h *Health) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Check runs all checks concurrently.
+func (h *Health) Check(ctx context.Context) Report {
+	h.mu.RLock()
+	names := append([]string(nil), h.names...)
+	checks := make(map[string]CheckFunc, len(h.checks))
+	for k, v := range h.checks {
+		checks[k] = v
+	}
+	var info map[string]any
+	if len(h.info) > 0 {
+		info = make(map[string]any, len(h.info))
+		for k, fn := range h.info {
+			info[k] = fn()
+		}
+	}
+	h.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(names)), Info: info}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := run(ctx, checks[name], h.timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	if h.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func run(ctx context.Context, fn CheckFunc, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err := fn(ctx)
+	res := CheckResult{Status: StatusOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// TryLock polls lock until it succeeds or ctx is done. In-memory stores use it
+// to prove they are not wedged; the caller must unlock on success.
+func TryLock(ctx context.Context, lock func() bool) error {
+	for !lock() {
+		select {
+		case <-ctx.Done():
+			return errors.New("store lock not acquired in time")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Supported locales. English is the source language: messages in code are
+// written in English and double as catalog keys.
+const (
+	English = "en"
+	Persian = "fa"
+	Default = English
+)
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+// catalogs maps locale -> English source message -> translation.
+var catalogs = map[string]map[string]string{}
+
+var matcher = language.NewMatcher([]language.Tag{language.English, language.Persian})
+
+func init() {
+	files, err := catalogFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range files {
+		b, err := catalogFiles.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(b, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", f.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(f.Name(), ".json")] = catalog
+	}
+}
+
+// Match picks the best supported locale for an Accept-Language header value;
+// ok is false when the header names no supported language.
+func Match(acceptLanguage string) (locale string, ok bool) {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default, false
+	}
+	_, idx, conf := matcher.Match(tags...)
+	if conf == language.No {
+		return Default, false
+	}
+	return []string{English, Persian}[idx], true
+}
+
+// Normalize returns locale if it is supported, otherwise Default.
+func Normalize(locale string) string {
+	if locale == English || locale == Persian {
+		return locale
+	}
+	return Default
+}
+
+// T translates an English message (optionally a fmt format) into locale,
+// falling back to the English text when the catalog has no entry.
+func T(locale, message string, args ...any) string {
+	if tr, ok := catalogs[locale][message]; ok {
+		message = tr
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+type ctxKey struct{}
+
+// WithLocale returns a copy of ctx carrying locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, locale)
+}
+
+// FromContext returns the request locale, or Default.
+func FromContext(ctx context.Context) string {
+	if l, ok := ctx.Value(ctxKey{}).(string); ok {
+		return l
+	}
+	return Default
+}
@@ -2,36 +2,165 @@ package jwt
 
 import (
 	"errors"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte("my-secret-key") // use env var in real apps
+var (
+	ErrTokenExpired = errors.New("token expired")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// KeySet holds the HMAC keys by id; Active signs new tokens, all keys verify.
+type KeySet struct {
+	Active string
+	Keys   map[string][]byte
+	TTL    time.Duration
+}
+
+var keys atomic.Pointer[KeySet]
+
+func init() {
+	keys.Store(&KeySet{
+		Active: "default",
+		Keys:   map[string][]byte{"default": []byte("my-secret-key")}, // use env var in real apps
+		TTL:    24 * time.Hour,
+	})
+}
+
+// Validate reports whether ks can sign tokens.
+func (ks *KeySet) Validate() error {
+	if _, ok := ks.Keys[ks.Active]; !ok {
+		return errors.New("active key not in key set")
+	}
+	return nil
+}
+
+// SetKeys atomically replaces the key set used for signing and verification.
+func SetKeys(ks *KeySet) error {
+	if err := ks.Validate(); err != nil {
+		return err
+	}
+	keys.Store(ks)
+	return nil
+}
+
+// CreateJWT generates a signed access token for subject (a phone or email)
+// within session.
+func CreateJWT(subject, session string) (string, error) {
+	ks := keys.Load()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": subject,
+		"sid": session,
+		"exp": jwt.NewNumericDate(time.Now().Add(ks.TTL)),
+	})
+	token.Header["kid"] = ks.Active
+	return token.SignedString(ks.Keys[ks.Active])
+}
+
+// ValidateJWT parses an access token, checks its signature against the key
+// set and returns its subject and session. Tokens issued for another
+// audience, such as magic links or second-factor challenges, and tokens
+// without a session fail.
+func ValidateJWT(tokenStr string) (subject, session string, err error) {
+	claims, err := parse(tokenStr)
+	if err != nil {
+		return "", "", err
+	}
+	if aud, _ := claims.GetAudience(); len(aud) > 0 {
+		return "", "", ErrInvalidToken
+	}
+	subject, _ = claims.GetSubject()
+	session, _ = claims["sid"].(string)
+	if subject == "" || session == "" {
+		return "", "", ErrInvalidToken
+	}
+	return subject, session, nil
+}
+
+// Audiences scope single-purpose tokens so they can't be used as access tokens.
+const (
+	linkAudience      = "magic-link"
+	challengeAudience = "mfa-challenge"
+)
+
+// CreateLinkToken signs a token for a magic sign-in link. nonce ties it to
+// one issued OTP so the link works once.
+func CreateLinkToken(subject, nonce string, ttl time.Duration) (string, error) {
+	return createScoped(linkAudience, subject, nonce, ttl)
+}
 
-// CreateJWT generates a signed JWT for given phone.
-func CreateJWT(phone string) (string, error) {
+// ValidateLinkToken checks a magic-link token and returns its subject and nonce.
+func ValidateLinkToken(tokenStr string) (subject, nonce string, err error) {
+	return validateScoped(linkAudience, tokenStr)
+}
+
+// CreateChallengeToken signs the token a user with a second factor gets after
+// the first one; id names the pending challenge.
+func CreateChallengeToken(subject, id string, ttl time.Duration) (string, error) {
+	return createScoped(challengeAudience, subject, id, ttl)
+}
+
+// ValidateChallengeToken checks a second-factor challenge token and returns
+// its subject and challenge id.
+func ValidateChallengeToken(tokenStr string) (subject, id string, err error) {
+	return validateScoped(challengeAudience, tokenStr)
+}
+
+func createScoped(aud, subject, jti string, ttl time.Duration) (string, error) {
+	ks := keys.Load()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": phone,
-		"exp": jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+		"sub": subject,
+		"aud": aud,
+		"jti": jti,
+		"exp": jwt.NewNumericDate(time.Now().Add(ttl)),
 	})
-	return token.SignedString(jwtKey)
+	token.Header["kid"] = ks.Active
+	return token.SignedString(ks.Keys[ks.Active])
+}
+
+func validateScoped(aud, tokenStr string) (subject, jti string, err error) {
+	claims, err := parse(tokenStr, jwt.WithAudience(aud))
+	if err != nil {
+		return "", "", err
+	}
+	subject, _ = claims.GetSubject()
+	jti, _ = claims["jti"].(string)
+	if subject == "" || jti == "" {
+		return "", "", ErrInvalidToken
+	}
+	return subject, jti, nil
 }
 
-// JWTAuth is middleware that checks Bearer token.
-func ValidateJWT(tokenStr string) (jwt.MapClaims, error) {
+func parse(tokenStr string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	ks := keys.Load()
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
 		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
 			return nil, errors.New("unexpected signing method")
 		}
-		return jwtKey, nil
-	})
+		// Tokens issued before key ids were introduced carry no kid
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = ks.Active
+		}
+		key, ok := ks.Keys[kid]
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		return key, nil
+	}, opts...)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrTokenExpired
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		return claims, nil
 	}
-	return nil, errors.New("invalid token")
+	return nil, ErrInvalidToken
 }
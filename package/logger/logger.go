@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Options configures New. Level is a LevelVar so it can be changed on reload.
+type Options struct {
+	Format string // "json" or "text"
+	Level  *slog.LevelVar
+	Redact bool
+}
+
+// New builds the service logger writing to w.
+func New(w io.Writer, opts Options) *slog.Logger {
+	ho := &slog.HandlerOptions{Level: opts.Level}
+	if opts.Redact {
+		ho.ReplaceAttr = redact
+	}
+	if opts.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, ho))
+	}
+	return slog.New(slog.NewJSONHandler(w, ho))
+}
+
+// ParseLevel maps "debug", "info", "warn" and "error" to a slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+type ctxKey struct{}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the request-scoped logger, or fallback if there is none.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+var (
+	// Mobile numbers as 09..., +989... or 00989..., but not digit runs that
+	// merely contain one, like IDs
+	phonePattern = regexp.MustCompile(`(?:\+98|\b0098|\b0)9\d{9}\b`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// secretKeys are attribute keys whose values are never logged.
+var secretKeys = map[string]bool{"otp": true, "code": true, "body": true}
+
+// redact masks OTP codes, message bodies carrying them, Iranian phone numbers
+// and email addresses wherever they appear in attributes.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[REDACTED]")
+	}
+	if a.Value.Kind() == slog.KindString {
+		s := a.Value.String()
+		masked := emailPattern.ReplaceAllStringFunc(phonePattern.ReplaceAllStringFunc(s, MaskPhone), MaskEmail)
+		if masked != s {
+			return slog.String(a.Key, masked)
+		}
+	}
+	return a
+}
+
+// MaskPhone keeps the operator prefix and last two digits: 0912*****89, or
+// +98912*****89 in international form.
+func MaskPhone(phone string) string {
+	keep := len("0912")
+	switch {
+	case strings.HasPrefix(phone, "+98"):
+		keep = len("+98912")
+	case strings.HasPrefix(phone, "0098"):
+		keep = len("0098912")
+	}
+	if len(phone) < keep+3 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:keep] + strings.Repeat("*", len(phone)-keep-2) + phone[len(phone)-2:]
+}
+
+// MaskEmail keeps the first character of the local part and the domain:
+// a***@example.com.
+func MaskEmail(email string) string {
+	local, domain, _ := strings.Cut(email, "@")
+	if local == "" {
+		return "***@" + domain
+	}
+	return local[:1] + "***@" + domain
+}
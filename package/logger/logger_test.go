@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"local number", "09123456789", "0912*****89"},
+		{"international number", "+989123456789", "+98912*****89"},
+		{"international number with 00", "00989123456789", "0098912*****89"},
+		{"number in text", "sent to 09123456789.", "sent to 0912*****89."},
+		{"number in a path", "/v1/users/09123456789", "/v1/users/0912*****89"},
+		{"longer digit run", "order 109123456789", "order 109123456789"},
+		{"number followed by digits", "091234567890", "091234567890"},
+		{"number inside a word", "id09123456789", "id09123456789"},
+		{"email", "user@example.com", "u***@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(nil, slog.String("msg", tt.in)).Value.String(); got != tt.want {
+				t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
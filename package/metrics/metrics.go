@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// HTTP RED metrics, labelled by route pattern rather than raw path.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Auth funnel metrics.
+var (
+	OTPGenerated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otp_generated_total",
+		Help: "OTP codes generated by delivery channel.",
+	}, []string{"channel"})
+
+	OTPSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otp_sent_total",
+		Help: "OTP codes handed to a delivery channel successfully.",
+	}, []string{"channel"})
+
+	OTPSendFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otp_send_failed_total",
+		Help: "OTP codes a delivery channel failed to send.",
+	}, []string{"channel"})
+
+	OTPDispatchLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "otp_dispatch_latency_seconds",
+		Help:    "Time from queueing an OTP to a provider accepting it, by channel.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"channel"})
+
+	OTPSendAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otp_send_attempts_total",
+		Help: "Sends attempted per provider, by result (ok or error).",
+	}, []string{"provider", "result"})
+
+	OTPDeliveryReports = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otp_delivery_reports_total",
+		Help: "Provider delivery reports by provider and status (delivered or undelivered).",
+	}, []string{"provider", "status"})
+
+	ProviderCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "otp_provider_circuit_open",
+		Help: "1 while the provider's circuit breaker is rejecting sends.",
+	}, []string{"provider"})
+
+	OTPVerifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otp_verifications_total",
+		Help: "OTP verifications by outcome (ok, wrong, expired, locked).",
+	}, []string{"outcome"})
+
+	MFAVerifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mfa_verifications_total",
+		Help: "Second-factor checks by method (totp or recovery) and outcome (ok, wrong, locked).",
+	}, []string{"method", "outcome"})
+
+	OTPTestNumberUses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "otp_test_number_uses_total",
+		Help: "OTP requests and verifications by configured test numbers, by action (request or verify).",
+	}, []string{"action"})
+
+	RateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limit_rejections_total",
+		Help: "Requests rejected by the rate limiter, by policy.",
+	}, []string{"policy"})
+
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Successful verifications by kind (registration or login).",
+	}, []string{"kind"})
+
+	ChallengeVerifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "challenge_verifications_total",
+		Help: "Bot challenges checked on OTP requests, by provider and outcome (ok, failed, missing, error).",
+	}, []string{"provider", "outcome"})
+
+	AccessRuleHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "access_rule_hits_total",
+		Help: "OTP requests decided by an access rule, by rule kind and action (allow or deny).",
+	}, []string{"kind", "action"})
+
+	RiskDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "risk_decisions_total",
+		Help: "Sign-in risk assessments by action (allow, step_up, block).",
+	}, []string{"action"})
+
+	RiskSignals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "risk_signals_total",
+		Help: "Risk signals raised on sign-ins, by signal.",
+	}, []string{"signal"})
+)
+
+// Verification outcomes.
+const (
+	OutcomeOK      = "ok"
+	OutcomeWrong   = "wrong"
+	OutcomeExpired = "expired"
+	OutcomeLocked  = "locked"
+)
+
+// RegisterStateGauges exposes the size of the in-memory stores and the
+// dispatch queue.
+func RegisterStateGauges(liveOTPs, rateLimiterKeys, dispatchQueue, sessions func() int) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "otp_live_codes",
+		Help: "OTP codes currently stored and not yet swept.",
+	}, func() float64 { return float64(liveOTPs()) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "rate_limiter_keys",
+		Help: "Keys currently tracked by the rate limiter.",
+	}, func() float64 { return float64(rateLimiterKeys()) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "otp_dispatch_queue_depth",
+		Help: "OTP messages waiting for a dispatch worker.",
+	}, func() float64 { return float64(dispatchQueue()) })
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "sessions_stored",
+		Help: "Sign-in sessions currently stored and not yet swept.",
+	}, func() float64 { return float64(sessions()) })
+}
@@ -1,63 +1,313 @@
 package otp
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/big"
+	"net/url"
 	"sync"
 	"time"
+
+	"dekamond-task/package/dispatch"
+	"dekamond-task/package/health"
+	"dekamond-task/package/i18n"
+	"dekamond-task/package/jwt"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/metrics"
+	otptemplate "dekamond-task/package/otp_template"
+	"dekamond-task/package/schedule"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("dekamond-task/package/otp")
+
+var (
+	ErrExpired              = errors.New("OTP expired or not found")
+	ErrInvalid              = errors.New("invalid OTP")
+	ErrLocked               = errors.New("too many failed attempts")
+	ErrMagicLinkUnavailable = errors.New("magic links are only sent by email and must be enabled")
 )
 
-// OTPService manages OTP generation and verification.
+// Policy controls how codes are generated and how long they stay valid.
+// MagicLinkURL is where magic links point, with ?token= appended; empty
+// disables them. TestNumbers get their fixed code and no message.
+type Policy struct {
+	Length       int
+	TTL          time.Duration
+	MaxAttempts  int
+	MagicLinkURL string
+	TestNumbers  map[string]string // phone -> fixed code
+}
+
+// Request describes an OTP to issue. To is a phone number or, for the email
+// channel, an email address.
+type Request struct {
+	To        string
+	Channel   string
+	App       string // selects the message template
+	MagicLink bool   // also send a single-use sign-in link
+}
+
+// OTPService manages OTP generation and verification. Everything is keyed by
+// the identifier the code was sent to, a phone or an email.
 type OTPService struct {
 	mu        sync.Mutex
-	codes     map[string]string    // phone -> otp code
-	expiresAt map[string]time.Time // phone -> expiration time
+	log       *slog.Logger
+	policy    Policy
+	messages  *otptemplate.Renderer
+	sender    *dispatch.Dispatcher
+	codes     map[string]string    // identifier -> otp code
+	links     map[string]string    // identifier -> magic link nonce
+	expiresAt map[string]time.Time // identifier -> expiration time
+	attempts  map[string]int       // identifier -> failed verification attempts
 }
 
-func NewOTPService() *OTPService {
+func NewOTPService(p Policy, messages *otptemplate.Renderer, sender *dispatch.Dispatcher, log *slog.Logger) *OTPService {
 	return &OTPService{
+		log:       log,
+		policy:    p,
+		messages:  messages,
+		sender:    sender,
 		codes:     make(map[string]string),
+		links:     make(map[string]string),
 		expiresAt: make(map[string]time.Time),
+		attempts:  make(map[string]int),
+	}
+}
+
+// SetPolicy swaps the policy; codes already issued keep their expiry.
+func (o *OTPService) SetPolicy(p Policy) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.policy = p
+}
+
+// GenerateOTP creates and stores an OTP for req.To and queues its message,
+// rendered for req.App and the locale in ctx. It returns the delivery ID,
+// which is empty for a test number: its fixed code is stored and nothing is
+// sent.
+func (o *OTPService) GenerateOTP(ctx context.Context, req Request) (string, error) {
+	ctx, span := tracer.Start(ctx, "OTPService.GenerateOTP", trace.WithAttributes(
+		attribute.String("otp.channel", req.Channel), attribute.Bool("otp.magic_link", req.MagicLink)))
+	defer span.End()
+
+	if !o.sender.Serves(req.Channel) {
+		return "", dispatch.ErrNoProvider
 	}
+	code, msg, test, err := o.store(ctx, req)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+	if test {
+		metrics.OTPTestNumberUses.WithLabelValues("request").Inc()
+		logger.FromContext(ctx, o.log).Warn("OTP test number used", "audit", true, "action", "request",
+			"to", req.To, "channel", req.Channel)
+		return "", nil
+	}
+	metrics.OTPGenerated.WithLabelValues(msg.Channel).Inc()
+
+	// Queued outside the lock so a slow provider never holds up other logins
+	id, err := o.sender.Enqueue(ctx, msg)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		o.mu.Lock()
+		if o.codes[req.To] == code {
+			o.remove(req.To)
+		}
+		o.mu.Unlock()
+		return "", err
+	}
+	logger.FromContext(ctx, o.log).Info("OTP generated", "to", req.To, "delivery_id", id)
+	return id, nil
 }
 
-// GenerateOTP creates and stores an OTP for the given phone.
-func (o *OTPService) GenerateOTP(phone string) (string, error) {
+// store saves a fresh code (and link nonce) for req.To and renders its
+// message. For a test number it saves the fixed code and reports test.
+func (o *OTPService) store(ctx context.Context, req Request) (code string, msg dispatch.Message, test bool, err error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	otp := o.generateSecureOTP()
-	o.codes[phone] = otp
-	o.expiresAt[phone] = time.Now().Add(2 * time.Minute)
-	// Print to console (simulate sending SMS)
-	log.Println("OTP for", phone, "is", otp)
-	return otp, nil
+	if req.MagicLink && (req.Channel != otptemplate.ChannelEmail || o.policy.MagicLinkURL == "") {
+		return "", dispatch.Message{}, false, ErrMagicLinkUnavailable
+	}
+	if fixed, ok := o.policy.TestNumbers[req.To]; ok {
+		o.remove(req.To)
+		o.codes[req.To] = fixed
+		o.expiresAt[req.To] = time.Now().Add(o.policy.TTL)
+		return fixed, dispatch.Message{}, true, nil
+	}
+	otp, err := generateSecureOTP(o.policy.Length)
+	if err != nil {
+		return "", dispatch.Message{}, false, err
+	}
+	data := otptemplate.Data{Code: otp, ExpiryMinutes: int(o.policy.TTL.Round(time.Minute).Minutes())}
+	var nonce string
+	if req.MagicLink {
+		if nonce, data.Link, err = o.magicLink(req.To); err != nil {
+			return "", dispatch.Message{}, false, err
+		}
+	}
+	locale := i18n.FromContext(ctx)
+	body, err := o.messages.Render(req.Channel, locale, req.App, data)
+	if err != nil {
+		return "", dispatch.Message{}, false, fmt.Errorf("render OTP message: %w", err)
+	}
+
+	expires := time.Now().Add(o.policy.TTL)
+	o.codes[req.To] = otp
+	o.expiresAt[req.To] = expires
+	delete(o.attempts, req.To)
+	delete(o.links, req.To)
+	if nonce != "" {
+		o.links[req.To] = nonce
+	}
+	return otp, dispatch.Message{Channel: req.Channel, To: req.To, Body: body, Locale: locale, ExpiresAt: expires}, false, nil
+}
+
+// magicLink returns a fresh nonce and the signed link carrying it.
+func (o *OTPService) magicLink(to string) (nonce, link string, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate magic link: %w", err)
+	}
+	nonce = hex.EncodeToString(b)
+	token, err := jwt.CreateLinkToken(to, nonce, o.policy.TTL)
+	if err != nil {
+		return "", "", fmt.Errorf("sign magic link: %w", err)
+	}
+	return nonce, o.policy.MagicLinkURL + "?token=" + url.QueryEscape(token), nil
 }
 
 // ValidateOTP checks if the provided OTP is correct and not expired.
-func (o *OTPService) ValidateOTP(phone, code string) error {
+func (o *OTPService) ValidateOTP(ctx context.Context, to, code string) (err error) {
+	ctx, span := tracer.Start(ctx, "OTPService.ValidateOTP")
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	err = o.check(ctx, to, o.codes, code)
+	if _, test := o.policy.TestNumbers[to]; test {
+		outcome := "ok"
+		if err != nil {
+			outcome = err.Error()
+		}
+		metrics.OTPTestNumberUses.WithLabelValues("verify").Inc()
+		logger.FromContext(ctx, o.log).Warn("OTP test number used", "audit", true, "action", "verify",
+			"to", to, "outcome", outcome)
+	}
+	return err
+}
+
+// IsTestNumber reports whether to is a test number of the policy.
+func (o *OTPService) IsTestNumber(to string) bool {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	exp, exists := o.expiresAt[phone]
+	_, ok := o.policy.TestNumbers[to]
+	return ok
+}
+
+// ValidateMagicLink consumes the magic link nonce issued with the OTP for to.
+// The link and the code share one lifetime: using either spends both.
+func (o *OTPService) ValidateMagicLink(ctx context.Context, to, nonce string) (err error) {
+	ctx, span := tracer.Start(ctx, "OTPService.ValidateMagicLink")
+	defer func() {
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.check(ctx, to, o.links, nonce)
+}
+
+// check compares secret with the one stored for to in secrets, counting
+// failures towards the lockout. Callers hold o.mu.
+func (o *OTPService) check(ctx context.Context, to string, secrets map[string]string, secret string) error {
+	exp, exists := o.expiresAt[to]
 	if !exists || time.Now().After(exp) {
-		return errors.New("OTP expired or not found")
+		metrics.OTPVerifications.WithLabelValues(metrics.OutcomeExpired).Inc()
+		return ErrExpired
 	}
-	if o.codes[phone] != code {
-		return errors.New("invalid OTP")
+	want, ok := secrets[to]
+	if !ok || subtle.ConstantTimeCompare([]byte(want), []byte(secret)) != 1 {
+		o.attempts[to]++
+		if o.attempts[to] >= o.policy.MaxAttempts {
+			// Burn the code so it can't be brute-forced
+			o.remove(to)
+			metrics.OTPVerifications.WithLabelValues(metrics.OutcomeLocked).Inc()
+			logger.FromContext(ctx, o.log).Warn("OTP locked after too many failed attempts", "to", to)
+			return ErrLocked
+		}
+		metrics.OTPVerifications.WithLabelValues(metrics.OutcomeWrong).Inc()
+		return ErrInvalid
 	}
 	// Successful validation; remove OTP so it can't be reused
-	delete(o.codes, phone)
-	delete(o.expiresAt, phone)
+	o.remove(to)
+	metrics.OTPVerifications.WithLabelValues(metrics.OutcomeOK).Inc()
 	return nil
 }
 
-// generateSecureOTP returns a 4-digit random OTP (crypto/rand).
-func (o *OTPService) generateSecureOTP() string {
-	b := make([]byte, 2)
-	_, err := rand.Read(b)
+// Len returns the number of stored codes, expired ones included until swept.
+func (o *OTPService) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.codes)
+}
+
+// Ping reports whether the store can be locked before ctx is done.
+func (o *OTPService) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, o.mu.TryLock); err != nil {
+		return err
+	}
+	o.mu.Unlock()
+	return nil
+}
+
+// RunSweeper drops expired codes every interval until ctx is done.
+func (o *OTPService) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, o.sweep)
+}
+
+func (o *OTPService) sweep() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	for to, exp := range o.expiresAt {
+		if now.After(exp) {
+			o.remove(to)
+		}
+	}
+}
+
+func (o *OTPService) remove(to string) {
+	delete(o.codes, to)
+	delete(o.links, to)
+	delete(o.expiresAt, to)
+	delete(o.attempts, to)
+}
+
+// generateSecureOTP returns a random numeric OTP of the given length (crypto/rand).
+func generateSecureOTP(length int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil)
+	n, err := rand.Int(rand.Reader, max)
 	if err != nil {
-		log.Fatal("Error generating OTP:", err)
+		return "", fmt.Errorf("generate OTP: %w", err)
 	}
-	return fmt.Sprintf("%04d", int(b[0])%10000)
+	return fmt.Sprintf("%0*d", length, n), nil
 }
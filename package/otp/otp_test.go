@@ -0,0 +1,93 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dekamond-task/package/dispatch"
+	otptemplate "dekamond-task/package/otp_template"
+)
+
+const testEmail = "user@example.org"
+
+func newTestService(t *testing.T) *OTPService {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	set, err := otptemplate.Compile("web", "en", []otptemplate.App{{ID: "web", Name: "Dekamond"}}, []otptemplate.Template{
+		{Channel: otptemplate.ChannelEmail, Locale: "en", Text: "Sign in\nYour code is {{.Code}} or open {{.Link}}"},
+	})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	d := dispatch.NewDispatcher(dispatch.Options{QueueSize: 10}, log)
+	d.AddProvider(otptemplate.ChannelEmail, dispatch.NewConsoleProvider("console", log))
+	return NewOTPService(Policy{
+		Length:       5,
+		TTL:          time.Minute,
+		MaxAttempts:  3,
+		MagicLinkURL: "https://example.org/auth/link",
+	}, otptemplate.NewRenderer(set), d, log)
+}
+
+// issue requests a code with a magic link and returns both secrets.
+func issue(t *testing.T, o *OTPService) (code, nonce string) {
+	t.Helper()
+	if _, err := o.GenerateOTP(context.Background(), Request{To: testEmail, Channel: otptemplate.ChannelEmail, MagicLink: true}); err != nil {
+		t.Fatalf("GenerateOTP: %v", err)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.codes[testEmail], o.links[testEmail]
+}
+
+func TestMagicLinkIsSingleUse(t *testing.T) {
+	type use func(o *OTPService, code, nonce string) error
+	link := func(o *OTPService, _, nonce string) error {
+		return o.ValidateMagicLink(context.Background(), testEmail, nonce)
+	}
+	otp := func(o *OTPService, code, _ string) error {
+		return o.ValidateOTP(context.Background(), testEmail, code)
+	}
+	wrongLink := func(o *OTPService, _, _ string) error {
+		return o.ValidateMagicLink(context.Background(), testEmail, "0123456789abcdef0123456789abcdef")
+	}
+
+	tests := []struct {
+		name  string
+		steps []use
+		want  []error
+	}{
+		{"link works once", []use{link, link}, []error{nil, ErrExpired}},
+		{"link spends the code", []use{link, otp}, []error{nil, ErrExpired}},
+		{"code spends the link", []use{otp, link}, []error{nil, ErrExpired}},
+		{"wrong nonce counts as a failed attempt", []use{wrongLink, link, link}, []error{ErrInvalid, nil, ErrExpired}},
+		{"wrong nonces lock the link", []use{wrongLink, wrongLink, wrongLink, link}, []error{ErrInvalid, ErrInvalid, ErrLocked, ErrExpired}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestService(t)
+			code, nonce := issue(t, o)
+			for i, step := range tt.steps {
+				if err := step(o, code, nonce); !errors.Is(err, tt.want[i]) {
+					t.Fatalf("step %d: got %v, want %v", i+1, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewRequestRevokesOldLink(t *testing.T) {
+	o := newTestService(t)
+	_, old := issue(t, o)
+	_, nonce := issue(t, o)
+	if err := o.ValidateMagicLink(context.Background(), testEmail, old); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("old link: got %v, want %v", err, ErrInvalid)
+	}
+	if err := o.ValidateMagicLink(context.Background(), testEmail, nonce); err != nil {
+		t.Fatalf("new link: %v", err)
+	}
+}
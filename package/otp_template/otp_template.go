@@ -0,0 +1,153 @@
+package otptemplate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"text/template"
+)
+
+// Channels a template can be written for.
+const (
+	ChannelSMS   = "sms"
+	ChannelVoice = "voice"
+	ChannelEmail = "email" // first rendered line is the subject
+)
+
+// App identifies a client app. Domain enables the iOS one-time-code autofill
+// line ("@domain #code"); AndroidHash is the 11-character SMS Retriever hash.
+type App struct {
+	ID          string
+	Name        string
+	Domain      string
+	AndroidHash string
+}
+
+// Template is the text for one channel and locale, optionally for one app
+// only (App == "" applies to every app).
+type Template struct {
+	Channel string
+	Locale  string
+	App     string
+	Text    string
+}
+
+// Data is what templates can reference.
+type Data struct {
+	Code          string
+	ExpiryMinutes int
+	Link          string // magic sign-in link, when one was requested
+	AppName       string
+	Domain        string
+	AndroidHash   string
+}
+
+var funcs = template.FuncMap{
+	// spaced reads digits one by one in voice calls: "1 2 3 4"
+	"spaced": func(s string) string { return strings.Join(strings.Split(s, ""), " ") },
+}
+
+// Set is a compiled, immutable collection of apps and templates.
+type Set struct {
+	defaultApp     string
+	fallbackLocale string
+	apps           map[string]App
+	templates      map[string]*template.Template // channel/locale/app -> template
+}
+
+// Compile parses every template and checks the apps. Every channel needs an
+// all-apps template in fallbackLocale, used when a locale has none.
+func Compile(defaultApp, fallbackLocale string, apps []App, templates []Template) (*Set, error) {
+	s := &Set{
+		defaultApp:     defaultApp,
+		fallbackLocale: fallbackLocale,
+		apps:           make(map[string]App, len(apps)),
+		templates:      make(map[string]*template.Template, len(templates)),
+	}
+	for _, a := range apps {
+		if a.ID == "" || a.Name == "" {
+			return nil, errors.New("apps need an id and a name")
+		}
+		if a.AndroidHash != "" && len(a.AndroidHash) != 11 {
+			return nil, fmt.Errorf("app %q: android_hash must be 11 characters", a.ID)
+		}
+		s.apps[a.ID] = a
+	}
+	if _, ok := s.apps[defaultApp]; !ok {
+		return nil, fmt.Errorf("default app %q is not defined", defaultApp)
+	}
+	for _, t := range templates {
+		if t.Channel == "" || t.Locale == "" {
+			return nil, errors.New("templates need a channel and a locale")
+		}
+		if t.App != "" {
+			if _, ok := s.apps[t.App]; !ok {
+				return nil, fmt.Errorf("template for unknown app %q", t.App)
+			}
+		}
+		k := key(t.Channel, t.Locale, t.App)
+		tmpl, err := template.New(k).Funcs(funcs).Option("missingkey=error").Parse(t.Text)
+		if err != nil {
+			return nil, err
+		}
+		s.templates[k] = tmpl
+	}
+	for _, t := range templates {
+		if s.templates[key(t.Channel, fallbackLocale, "")] == nil {
+			return nil, fmt.Errorf("channel %s has no %q template for all apps", t.Channel, fallbackLocale)
+		}
+	}
+	return s, nil
+}
+
+func key(channel, locale, app string) string {
+	return channel + "/" + locale + "/" + app
+}
+
+// Renderer renders OTP messages from a Set that can be swapped on reload.
+type Renderer struct {
+	set atomic.Pointer[Set]
+}
+
+func NewRenderer(set *Set) *Renderer {
+	r := &Renderer{}
+	r.set.Store(set)
+	return r
+}
+
+// SetTemplates atomically replaces the templates.
+func (r *Renderer) SetTemplates(set *Set) {
+	r.set.Store(set)
+}
+
+// Render picks the most specific template for channel, locale and app (an
+// unknown app is treated as the default app) and executes it with d, after
+// filling in the app's fields.
+func (r *Renderer) Render(channel, locale, app string, d Data) (string, error) {
+	s := r.set.Load()
+	a, ok := s.apps[app]
+	if !ok {
+		a = s.apps[s.defaultApp]
+	}
+
+	var tmpl *template.Template
+	for _, k := range []string{
+		key(channel, locale, a.ID),
+		key(channel, locale, ""),
+		key(channel, s.fallbackLocale, a.ID),
+		key(channel, s.fallbackLocale, ""),
+	} {
+		if tmpl = s.templates[k]; tmpl != nil {
+			break
+		}
+	}
+	if tmpl == nil {
+		return "", fmt.Errorf("no %s template for locale %q", channel, locale)
+	}
+
+	d.AppName, d.Domain, d.AndroidHash = a.Name, a.Domain, a.AndroidHash
+	var b strings.Builder
+	err := tmpl.Execute(&b, d)
+	return b.String(), err
+}
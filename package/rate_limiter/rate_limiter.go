@@ -1,28 +1,68 @@
 package ratelimiter
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"sync"
 	"time"
+
+	"dekamond-task/package/health"
+	"dekamond-task/package/metrics"
+	"dekamond-task/package/schedule"
+)
+
+var (
+	ErrLimitExceeded = errors.New("too many requests")
+	ErrUnknownPolicy = errors.New("unknown rate limit policy")
 )
 
+// Policy allows Limit requests per key within a sliding Window.
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+// pressureWindow is how far back RecentRejections looks, in seconds.
+const pressureWindow = 60
+
 type RateLimiter struct {
 	mu       sync.Mutex
-	requests map[string][]time.Time // key -> timestamps
+	policies map[string]Policy      // policy name -> limits
+	requests map[string][]time.Time // policy:key -> timestamps
+	rejected [pressureWindow]struct {
+		sec int64 // unix second the count is for
+		n   int
+	}
+}
+
+// NewRateLimiter creates a RateLimiter with the given named policies.
+func NewRateLimiter(policies map[string]Policy) *RateLimiter {
+	return &RateLimiter{
+		policies: policies,
+		requests: make(map[string][]time.Time),
+	}
 }
 
-// NewRateLimiter creates a RateLimiter.
-func NewRateLimiter() *RateLimiter {
-	return &RateLimiter{requests: make(map[string][]time.Time)}
+// SetPolicies atomically replaces the policies; recorded requests are kept.
+func (rl *RateLimiter) SetPolicies(policies map[string]Policy) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.policies = policies
 }
 
-// Allow checks and records a new request; returns error if limit exceeded.
-func (rl *RateLimiter) Allow(key string) error {
+// Allow checks and records a new request under the named policy; returns error if limit exceeded.
+func (rl *RateLimiter) Allow(policy, key string) error {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	p, ok := rl.policies[policy]
+	if !ok {
+		return ErrUnknownPolicy
+	}
 	now := time.Now()
-	windowStart := now.Add(-10 * time.Minute)
-	times := rl.requests[key]
+	windowStart := now.Add(-p.Window)
+	bucket := policy + ":" + key
+	times := rl.requests[bucket]
 	// Filter out old requests
 	var recent []time.Time
 	for _, t := range times {
@@ -30,11 +70,88 @@ func (rl *RateLimiter) Allow(key string) error {
 			recent = append(recent, t)
 		}
 	}
-	if len(recent) >= 3 {
-		return errors.New("rate limit exceeded")
+	if len(recent) >= p.Limit {
+		rl.requests[bucket] = recent
+		metrics.RateLimitRejections.WithLabelValues(policy).Inc()
+		sec := now.Unix()
+		if b := &rl.rejected[sec%pressureWindow]; b.sec == sec {
+			b.n++
+		} else {
+			b.sec, b.n = sec, 1
+		}
+		return ErrLimitExceeded
 	}
 	// Record this request
 	recent = append(recent, now)
-	rl.requests[key] = recent
+	rl.requests[bucket] = recent
 	return nil
 }
+
+// Used returns how many requests key has made within the window of the
+// named policy.
+func (rl *RateLimiter) Used(policy, key string) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	p, ok := rl.policies[policy]
+	if !ok {
+		return 0
+	}
+	windowStart := time.Now().Add(-p.Window)
+	n := 0
+	for _, t := range rl.requests[policy+":"+key] {
+		if t.After(windowStart) {
+			n++
+		}
+	}
+	return n
+}
+
+// RecentRejections returns how many requests were rejected in the last
+// minute, across all policies and keys.
+func (rl *RateLimiter) RecentRejections() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now().Unix()
+	n := 0
+	for _, b := range rl.rejected {
+		if now-b.sec < pressureWindow {
+			n += b.n
+		}
+	}
+	return n
+}
+
+// Len returns the number of tracked policy/key buckets.
+func (rl *RateLimiter) Len() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.requests)
+}
+
+// Ping reports whether the store can be locked before ctx is done.
+func (rl *RateLimiter) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, rl.mu.TryLock); err != nil {
+		return err
+	}
+	rl.mu.Unlock()
+	return nil
+}
+
+// RunSweeper drops keys with no requests left in their window every interval
+// until ctx is done.
+func (rl *RateLimiter) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, rl.sweep)
+}
+
+func (rl *RateLimiter) sweep() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	for bucket, times := range rl.requests {
+		policy, _, _ := strings.Cut(bucket, ":")
+		p, ok := rl.policies[policy]
+		if !ok || len(times) == 0 || times[len(times)-1].Before(now.Add(-p.Window)) {
+			delete(rl.requests, bucket)
+		}
+	}
+}
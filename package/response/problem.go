@@ -0,0 +1,181 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"dekamond-task/package/i18n"
+	"dekamond-task/package/validator"
+)
+
+// APIError is an error with an HTTP status and a stable machine-readable code
+// that clients can switch on instead of matching messages.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string // English text or fmt format; translated when written
+	Args    []any
+	Errors  []validator.FieldError // per-field details for validation failures
+}
+
+func (e *APIError) Error() string { return i18n.T(i18n.English, e.Message, e.Args...) }
+
+// NewError creates an APIError; message may be a fmt format for args.
+func NewError(status int, code, message string, args ...any) *APIError {
+	return &APIError{Status: status, Code: code, Message: message, Args: args}
+}
+
+// Generic errors not owned by a domain package.
+var (
+	ErrInvalidRequest     = NewError(http.StatusBadRequest, "invalid_request", "request object is not valid")
+	ErrMissingToken       = NewError(http.StatusUnauthorized, "token_missing", "missing token")
+	ErrInvalidSignature   = NewError(http.StatusUnauthorized, "signature_invalid", "invalid or expired webhook signature")
+	ErrClientCertRequired = NewError(http.StatusForbidden, "client_certificate_required", "client certificate required")
+	ErrNotFound           = NewError(http.StatusNotFound, "not_found", "not found")
+	ErrMethodNotAllowed   = NewError(http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	ErrInternal           = NewError(http.StatusInternalServerError, "internal_error", "internal server error")
+)
+
+// ProblemContentType is the RFC 9457 media type.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details body; Code and RequestID are
+// extension members.
+type Problem struct {
+	Type      string                 `json:"type" example:"urn:dekamond:error:otp_expired"`
+	Title     string                 `json:"title" example:"Unauthorized"`
+	Status    int                    `json:"status" example:"401"`
+	Detail    string                 `json:"detail,omitempty" example:"OTP expired or not found"`
+	Instance  string                 `json:"instance,omitempty" example:"/v1/auth/verify"`
+	Code      string                 `json:"code" example:"otp_expired"`
+	Errors    []validator.FieldError `json:"errors,omitempty"`
+	RequestID string                 `json:"request_id,omitempty" example:"4f1c2a9e0b7d4c3e8a6f5d2b1c0e9f8a"`
+}
+
+// Fail writes err as an error response. Known errors get their mapped status
+// and code; anything else is reported as an internal error without details.
+// Clients that accept application/problem+json get RFC 9457 problem details,
+// everyone else the usual envelope with a code field.
+func Fail(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := Classify(err)
+	w.Header().Add("Vary", "Accept")
+	if wantsProblem(r) {
+		writeProblem(w, r, apiErr)
+		return
+	}
+	writeEnvelope(w, apiErr)
+}
+
+// domainErrors maps sentinel errors of the domain packages, which know
+// nothing of HTTP, to a status and code; see RegisterError.
+var domainErrors []struct {
+	err    error
+	status int
+	code   string
+}
+
+// RegisterError makes Fail answer err, and errors wrapping it, with status
+// and code. Call it from init: the table is not locked.
+func RegisterError(err error, status int, code string) {
+	domainErrors = append(domainErrors, struct {
+		err    error
+		status int
+		code   string
+	}{err, status, code})
+}
+
+// Classify maps err to the APIError clients see.
+func Classify(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	for _, m := range domainErrors {
+		if errors.Is(err, m.err) {
+			return &APIError{Status: m.status, Code: m.code, Message: m.err.Error()}
+		}
+	}
+	return ErrInternal
+}
+
+// wantsProblem reports whether the Accept header prefers problem details to
+// the JSON envelope. The most specific range matching a type gives its
+// q-value, and q=0 rules it out; on equal q-values the type whose range comes
+// first wins, and the envelope when one range matches both.
+func wantsProblem(r *http.Request) bool {
+	var problem, envelope acceptance
+	for i, part := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		mediaRange, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		problem.consider(mediaRange, ProblemContentType, q, i)
+		envelope.consider(mediaRange, "application/json", q, i)
+	}
+	if problem.q <= 0 {
+		return false
+	}
+	return problem.q > envelope.q || problem.q == envelope.q && problem.pos < envelope.pos
+}
+
+// acceptance is how far a client accepts one media type: the q-value of the
+// most specific range matching it, and that range's place in the header.
+type acceptance struct {
+	specificity int // 0 while no range matched
+	q           float64
+	pos         int
+}
+
+func (a *acceptance) consider(mediaRange, mediaType string, q float64, pos int) {
+	var specificity int
+	switch {
+	case mediaRange == mediaType:
+		specificity = 3
+	case strings.HasSuffix(mediaRange, "/*") && mediaRange != "*/*" && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		specificity = 2
+	case mediaRange == "*/*":
+		specificity = 1
+	default:
+		return
+	}
+	if specificity > a.specificity {
+		*a = acceptance{specificity: specificity, q: q, pos: pos}
+	}
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, e *APIError) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(e.Status)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:      "urn:dekamond:error:" + e.Code,
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    localize(w, e.Message, e.Args...),
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		Errors:    e.Errors,
+		RequestID: w.Header().Get(RequestIDHeader),
+	})
+}
+
+func writeEnvelope(w http.ResponseWriter, e *APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{
+		Success:   false,
+		Message:   localize(w, e.Message, e.Args...),
+		Code:      e.Code,
+		Errors:    e.Errors,
+		RequestID: w.Header().Get(RequestIDHeader),
+	})
+}
@@ -0,0 +1,38 @@
+package response
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWantsProblem(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"*/*", false},
+		{"application/problem+json", true},
+		{"Application/Problem+JSON; charset=utf-8", true},
+		{"application/problem+json;q=0", false},
+		{"application/problem+json; q=0, */*", false},
+		{"application/problem+json, application/json", true},
+		{"application/json, application/problem+json", false},
+		{"application/json;q=0.5, application/problem+json", true},
+		{"application/problem+json;q=0.4, application/json;q=0.9", false},
+		{"application/json;q=0, application/*", true},
+		{"text/html, application/problem+json;q=0.9, */*;q=0.1", true},
+		{"application/xproblem+json", false},
+		{"application/problem+json;q=abc", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := wantsProblem(r); got != tt.want {
+			t.Errorf("wantsProblem(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
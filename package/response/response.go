@@ -3,19 +3,36 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+
+	"dekamond-task/package/i18n"
+	"dekamond-task/package/validator"
 )
 
+// RequestIDHeader is set on the response by the request ID middleware before
+// handlers run; error bodies repeat it for support tickets.
+const RequestIDHeader = "X-Request-ID"
+
+// localize translates message into the language the locale middleware
+// announced in Content-Language.
+func localize(w http.ResponseWriter, message string, args ...any) string {
+	return i18n.T(w.Header().Get("Content-Language"), message, args...)
+}
+
 // Generic API response wrapper
 type Response[T any] struct {
-	Success bool   `json:"success" example:"true"`
-	Data    *T     `json:"data,omitempty"`
-	Message string `json:"message" example:"OK"`
+	Success   bool   `json:"success" example:"true"`
+	Data      *T     `json:"data,omitempty"`
+	Message   string `json:"message" example:"OK"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // ErrorResponse is used for non-200 responses (no `data`)
 type ErrorResponse struct {
-	Success bool   `json:"success" example:"false"`
-	Message string `json:"message" example:"Invalid request"`
+	Success   bool                   `json:"success" example:"false"`
+	Message   string                 `json:"message" example:"Invalid request"`
+	Code      string                 `json:"code,omitempty" example:"invalid_request"`
+	Errors    []validator.FieldError `json:"errors,omitempty"`
+	RequestID string                 `json:"request_id,omitempty" example:"4f1c2a9e0b7d4c3e8a6f5d2b1c0e9f8a"`
 }
 
 // JSON sends a JSON response with status code.
@@ -26,7 +43,10 @@ func JSON[T any](w http.ResponseWriter, status int, success bool, data *T, messa
 	resp := Response[T]{
 		Success: success,
 		Data:    data,
-		Message: message,
+		Message: localize(w, message),
+	}
+	if !success {
+		resp.RequestID = w.Header().Get(RequestIDHeader)
 	}
 
 	_ = json.NewEncoder(w).Encode(resp)
@@ -57,7 +77,7 @@ func Paginated[T any](w http.ResponseWriter, data []T, total, page, size int, me
 
 	resp := PaginatedResponse[T]{
 		Success: true,
-		Message: message,
+		Message: localize(w, message),
 		Total:   total,
 		Page:    page,
 		Size:    size,
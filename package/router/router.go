@@ -0,0 +1,147 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dekamond-task/package/response"
+)
+
+// Middleware wraps a handler, e.g. middleware.Locale.
+type Middleware func(http.Handler) http.Handler
+
+// Router registers Go 1.22 method patterns ("POST /auth/verify") on a
+// ServeMux and lets groups of routes share a path prefix and middleware.
+type Router struct {
+	mux         *http.ServeMux
+	prefix      string
+	middleware  []Middleware
+	deprecation *Deprecation
+	usage       *sync.Map // pattern -> *atomic.Int64, shared by all groups
+}
+
+// Deprecation marks routes as deprecated. Responses carry the Deprecation
+// (RFC 9745) and, when set, Sunset (RFC 8594) headers.
+type Deprecation struct {
+	Since  time.Time
+	Sunset *time.Time // nil omits the Sunset header
+	// SuccessorPrefix, when set, advertises the same path under this prefix
+	// as the successor-version link.
+	SuccessorPrefix string
+}
+
+func New() *Router {
+	return &Router{mux: http.NewServeMux(), usage: &sync.Map{}}
+}
+
+// Group returns a router whose routes get prefix prepended and run behind
+// this router's middleware followed by mw.
+func (rt *Router) Group(prefix string, mw ...Middleware) *Router {
+	return &Router{
+		mux:         rt.mux,
+		prefix:      rt.prefix + prefix,
+		middleware:  append(append([]Middleware(nil), rt.middleware...), mw...),
+		deprecation: rt.deprecation,
+		usage:       rt.usage,
+	}
+}
+
+// Deprecated returns a copy of rt whose routes are marked deprecated and
+// counted in DeprecatedUsage.
+func (rt *Router) Deprecated(d Deprecation) *Router {
+	g := rt.Group("")
+	g.deprecation = &d
+	return g
+}
+
+// DeprecatedUsage reports how many times each deprecated route was hit.
+func (rt *Router) DeprecatedUsage() map[string]int64 {
+	out := make(map[string]int64)
+	rt.usage.Range(func(k, v any) bool {
+		out[k.(string)] = v.(*atomic.Int64).Load()
+		return true
+	})
+	return out
+}
+
+// Use adds middleware for routes registered on rt afterwards.
+func (rt *Router) Use(mw ...Middleware) {
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// Handle registers h for a "METHOD /path" pattern relative to the group
+// prefix. A bare method ("GET") matches the group prefix itself.
+func (rt *Router) Handle(pattern string, h http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok && strings.HasPrefix(pattern, "/") {
+		method, path = "", pattern
+	}
+	for i := len(rt.middleware) - 1; i >= 0; i-- {
+		h = rt.middleware[i](h)
+	}
+	full := strings.TrimSpace(method + " " + rt.prefix + path)
+	if rt.deprecation != nil {
+		h = rt.deprecate(full, h)
+	}
+	rt.mux.Handle(full, h)
+}
+
+func (rt *Router) deprecate(pattern string, next http.Handler) http.Handler {
+	d := *rt.deprecation
+	hits := &atomic.Int64{}
+	rt.usage.Store(pattern, hits)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+		if d.Sunset != nil {
+			w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if d.SuccessorPrefix != "" {
+			w.Header().Add("Link", "<"+d.SuccessorPrefix+r.URL.Path+`>; rel="successor-version"`)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rt *Router) HandleFunc(pattern string, fn http.HandlerFunc) {
+	rt.Handle(pattern, fn)
+}
+
+// ServeHTTP dispatches to the matching route. Unmatched paths and methods get
+// the mux's 404/405 status (and Allow header) with a JSON error body.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern == "" {
+		sw := &statusWriter{ResponseWriter: w}
+		rt.mux.ServeHTTP(sw, r)
+		switch sw.status {
+		case http.StatusNotFound:
+			response.Fail(w, r, response.ErrNotFound)
+		case http.StatusMethodNotAllowed:
+			response.Fail(w, r, response.ErrMethodNotAllowed)
+		default:
+			// Trailing-slash and path-cleaning redirects keep their Location header
+			w.WriteHeader(sw.status)
+		}
+		return
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// statusWriter keeps the headers the mux sets but swallows its plain-text body.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) { sw.status = status }
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return len(b), nil
+}
@@ -0,0 +1,21 @@
+package schedule
+
+import (
+	"context"
+	"time"
+)
+
+// Every calls fn every interval until ctx is done. Calls never overlap: a
+// tick missed while fn runs is dropped.
+func Every(ctx context.Context, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package schedule
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEveryRunsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, time.Millisecond, func() {
+			if calls.Add(1) == 3 { // a tick already due may still run once more
+				cancel()
+			}
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Every did not return after ctx was cancelled")
+	}
+	if n := calls.Load(); n < 3 {
+		t.Errorf("fn called %d times, want at least 3", n)
+	}
+}
@@ -0,0 +1,79 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 appendix B SHA-1 key "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC's 8-digit vectors cut to their last 6 digits.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1111111111, 0) // step 37037037
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, "050471", 37037037, true},
+		{"previous step within skew", rfcSecret, "081804", 37037036, true},
+		{"lower case padded secret", strings.ToLower(rfcSecret) + "====", "050471", 37037037, true},
+		{"far step", rfcSecret, "005924", 0, false},
+		{"wrong code", rfcSecret, "000000", 0, false},
+		{"short code", rfcSecret, "50471", 0, false},
+		{"bad secret", "not base32!", "050471", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ok := Verify(tt.secret, tt.code, now)
+			if s != tt.wantStep || ok != tt.wantOK {
+				t.Errorf("Verify() = %d, %v; want %d, %v", s, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecretRoundTrip(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	if _, ok := Verify(secret, code, now); !ok {
+		t.Errorf("Verify rejected the code for a generated secret")
+	}
+	if uri := URI(secret, "Dekamond", "09123456789"); !strings.HasPrefix(uri, "otpauth://totp/Dekamond:09123456789?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("URI() = %s", uri)
+	}
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an OTLP/HTTP receiver that keeps the names of the spans
+// exported to it.
+type collector struct {
+	mu       sync.Mutex
+	services []string
+	spans    []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, attr := range rs.Resource.GetAttributes() {
+			if attr.Key == "service.name" {
+				c.services = append(c.services, attr.Value.GetStringValue())
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				c.spans = append(c.spans, s.Name)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	out, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	_, _ = w.Write(out)
+}
+
+func TestSetupExportsToCollector(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		sampleRatio float64
+		wantSpans   []string
+	}{
+		{"exports sampled spans", true, 1, []string{"otp.generate"}},
+		{"ratio zero samples nothing", true, 0, nil},
+		{"disabled exports nothing", false, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			col := &collector{}
+			srv := httptest.NewServer(col)
+			defer srv.Close()
+			prev := otel.GetTracerProvider()
+			defer otel.SetTracerProvider(prev)
+
+			shutdown, err := Setup(context.Background(), Options{
+				Enabled:     tt.enabled,
+				Endpoint:    strings.TrimPrefix(srv.URL, "http://"),
+				Insecure:    true,
+				ServiceName: "dekamond-test",
+				SampleRatio: tt.sampleRatio,
+			})
+			if err != nil {
+				t.Fatalf("Setup: %v", err)
+			}
+			ctx, span := otel.Tracer("test").Start(context.Background(), "otp.generate")
+			traceID, spanID := IDs(ctx)
+			span.End()
+			if err := shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown: %v", err)
+			}
+
+			col.mu.Lock()
+			defer col.mu.Unlock()
+			if strings.Join(col.spans, ",") != strings.Join(tt.wantSpans, ",") {
+				t.Errorf("collector got spans %q, want %q", col.spans, tt.wantSpans)
+			}
+			if len(tt.wantSpans) > 0 {
+				if len(col.services) == 0 || col.services[0] != "dekamond-test" {
+					t.Errorf("collector got service names %q, want dekamond-test", col.services)
+				}
+				if len(traceID) != 32 || len(spanID) != 16 {
+					t.Errorf("IDs() = %q, %q; want hex trace and span IDs", traceID, spanID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package useragent
+
+import "strings"
+
+// maxLen caps names built from unrecognised agents.
+const maxLen = 64
+
+// Browsers and platforms in match order: Edge and Opera also claim to be
+// Chrome, and Chrome also claims to be Safari.
+var (
+	browsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"okhttp/", "Android app"},
+		{"CFNetwork/", "iOS app"},
+	}
+	platforms = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Macintosh", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// Device names the device a User-Agent header comes from for people
+// reviewing their sessions, like "Chrome on Windows". Unknown clients are
+// named by their first product token ("curl").
+func Device(ua string) string {
+	var browser, platform string
+	for _, b := range browsers {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, p := range platforms {
+		if strings.Contains(ua, p.token) {
+			platform = p.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	product, _, _ := strings.Cut(strings.TrimSpace(ua), "/")
+	product, _, _ = strings.Cut(product, " ")
+	if product == "" {
+		return "Unknown device"
+	}
+	if len(product) > maxLen {
+		product = product[:maxLen]
+	}
+	return product
+}
@@ -1,7 +1,129 @@
 package validator
 
 import (
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fa"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	fatranslations "github.com/go-playground/validator/v10/translations/fa"
+)
+
+var (
+	Validate    = validator.New()
+	translators = map[string]ut.Translator{}
 )
 
-var Validate = validator.New()
+func init() {
+	// Report fields by their JSON name, as clients send them
+	Validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	uni := ut.New(en.New(), en.New(), fa.New())
+	enTrans, _ := uni.GetTranslator("en")
+	faTrans, _ := uni.GetTranslator("fa")
+	if err := entranslations.RegisterDefaultTranslations(Validate, enTrans); err != nil {
+		panic(err)
+	}
+	if err := fatranslations.RegisterDefaultTranslations(Validate, faTrans); err != nil {
+		panic(err)
+	}
+	translators["en"] = enTrans
+	translators["fa"] = faTrans
+
+	// Rules the stock translations don't cover
+	registerTranslation(enTrans, "startswith", "{0} must start with '{1}'")
+	registerTranslation(faTrans, "startswith", "{0} باید با '{1}' شروع شود")
+	registerFieldTranslation(enTrans, "required_without", "{0} is required when {1} is not given")
+	registerFieldTranslation(faTrans, "required_without", "{0} در صورت ارسال نکردن {1} الزامی است")
+	registerFieldTranslation(enTrans, "excluded_with", "{0} cannot be given together with {1}")
+	registerFieldTranslation(faTrans, "excluded_with", "{0} را نمی‌توان همراه با {1} ارسال کرد")
+}
+
+func registerTranslation(translator ut.Translator, tag, text string) {
+	err := Validate.RegisterTranslation(tag, translator,
+		func(t ut.Translator) error { return t.Add(tag, text, true) },
+		func(t ut.Translator, fe validator.FieldError) string {
+			msg, _ := t.T(tag, fe.Field(), fe.Param())
+			return msg
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// registerFieldTranslation is registerTranslation for cross-field rules,
+// whose parameter is the other field's Go name; messages show it the way it
+// is spelled in JSON.
+func registerFieldTranslation(translator ut.Translator, tag, text string) {
+	err := Validate.RegisterTranslation(tag, translator,
+		func(t ut.Translator) error { return t.Add(tag, text, true) },
+		func(t ut.Translator, fe validator.FieldError) string {
+			msg, _ := t.T(tag, fe.Field(), snakeCase(fe.Param()))
+			return msg
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// snakeCase turns a Go field name like RecoveryCode into recovery_code.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// FieldError describes one failed validation rule.
+type FieldError struct {
+	Field   string `json:"field" example:"phone"`
+	Rule    string `json:"rule" example:"startswith"`
+	Param   string `json:"param,omitempty" example:"09"`
+	Message string `json:"message" example:"phone must start with '09'"`
+}
+
+// FieldErrors converts a Validate.Struct error into field errors translated
+// into locale ("en" or "fa"; anything else falls back to English).
+func FieldErrors(err error, locale string) []FieldError {
+	translator, ok := translators[locale]
+	if !ok {
+		translator = translators["en"]
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	out := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		out = append(out, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: fe.Translate(translator),
+		})
+	}
+	return out
+}
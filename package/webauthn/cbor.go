@@ -0,0 +1,134 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxCBORDepth bounds nesting; attestation objects need four levels at most.
+const maxCBORDepth = 16
+
+var errCBOR = errors.New("malformed CBOR")
+
+// decodeCBOR decodes the first CBOR item in b and returns it with the bytes
+// that follow. It covers what authenticators emit (RFC 8949 without
+// indefinite lengths): integers as int64, byte strings as []byte, text as
+// string, arrays as []any, maps as map[any]any keyed by int64 or string,
+// booleans, null and floats. Tags are skipped.
+func decodeCBOR(b []byte) (any, []byte, error) {
+	return decodeItem(b, 0)
+}
+
+func decodeItem(b []byte, depth int) (any, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, fmt.Errorf("%w: nested too deep", errCBOR)
+	}
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end", errCBOR)
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+
+	if major == 7 {
+		return decodeSimple(info, b)
+	}
+	n, b, err := decodeArgument(info, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return int64(n), b, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", errCBOR)
+		}
+		return -1 - int64(n), b, nil
+	case 2, 3:
+		if n > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("%w: string longer than input", errCBOR)
+		}
+		if major == 2 {
+			return b[:n:n], b[n:], nil
+		}
+		return string(b[:n]), b[n:], nil
+	case 4:
+		// Every item takes at least a byte, which bounds the allocation
+		if n > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("%w: array longer than input", errCBOR)
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], b, err = decodeItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return arr, b, nil
+	case 5:
+		if n > uint64(len(b))/2 {
+			return nil, nil, fmt.Errorf("%w: map longer than input", errCBOR)
+		}
+		m := make(map[any]any, n)
+		for range n {
+			var k, v any
+			if k, b, err = decodeItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key %T", errCBOR, k)
+			}
+			if _, dup := m[k]; dup {
+				return nil, nil, fmt.Errorf("%w: duplicate map key %v", errCBOR, k)
+			}
+			if v, b, err = decodeItem(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[k] = v
+		}
+		return m, b, nil
+	default: // 6: tag
+		return decodeItem(b, depth+1)
+	}
+}
+
+// decodeArgument reads the length or value that follows the initial byte.
+func decodeArgument(info byte, b []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24 && len(b) >= 1:
+		return uint64(b[0]), b[1:], nil
+	case info == 25 && len(b) >= 2:
+		return uint64(binary.BigEndian.Uint16(b)), b[2:], nil
+	case info == 26 && len(b) >= 4:
+		return uint64(binary.BigEndian.Uint32(b)), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return binary.BigEndian.Uint64(b), b[8:], nil
+	case info == 31:
+		return 0, nil, fmt.Errorf("%w: indefinite lengths are not supported", errCBOR)
+	}
+	return 0, nil, fmt.Errorf("%w: bad argument", errCBOR)
+}
+
+func decodeSimple(info byte, b []byte) (any, []byte, error) {
+	switch {
+	case info == 20:
+		return false, b, nil
+	case info == 21:
+		return true, b, nil
+	case info == 22 || info == 23:
+		return nil, b, nil
+	case info == 26 && len(b) >= 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported simple value %d", errCBOR, info)
+}
@@ -0,0 +1,123 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms (RFC 9053) accepted for credentials, in order of preference.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms is offered to authenticators when a passkey is created.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key labels and values.
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// publicKey is a decoded COSE_Key.
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key and returns it with the bytes that follow.
+func parsePublicKey(b []byte) (publicKey, []byte, error) {
+	item, rest, err := decodeCBOR(b)
+	if err != nil {
+		return publicKey{}, nil, err
+	}
+	m, ok := item.(map[any]any)
+	if !ok {
+		return publicKey{}, nil, fmt.Errorf("%w: key is not a map", ErrInvalid)
+	}
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, nil, fmt.Errorf("%w: bad P-256 key", ErrInvalid)
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return publicKey{}, nil, fmt.Errorf("%w: P-256 point not on curve", ErrInvalid)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return publicKey{alg: alg, key: key}, rest, nil
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, nil, fmt.Errorf("%w: bad Ed25519 key", ErrInvalid)
+		}
+		return publicKey{alg: alg, key: ed25519.PublicKey(x)}, rest, nil
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return publicKey{}, nil, fmt.Errorf("%w: RSA keys need at least 2048 bits", ErrInvalid)
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return publicKey{alg: alg, key: key}, rest, nil
+	}
+	return publicKey{}, nil, fmt.Errorf("%w: key type %d with algorithm %d", ErrUnsupported, kty, alg)
+}
+
+// verify checks sig over data.
+func (k publicKey) verify(data, sig []byte) error {
+	if !verifySignature(k.alg, k.key, data, sig) {
+		return fmt.Errorf("%w: bad signature", ErrInvalid)
+	}
+	return nil
+}
+
+// verifySignature checks sig over data with key under the COSE algorithm alg.
+func verifySignature(alg int64, key crypto.PublicKey, data, sig []byte) bool {
+	digest := sha256.Sum256(data)
+	switch alg {
+	case AlgES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && ecdsa.VerifyASN1(k, digest[:], sig)
+	case AlgEdDSA:
+		k, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(k, data, sig)
+	case AlgRS256:
+		k, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
+
+// verifyCertSignature checks an attestation signature made with the key of
+// an attestation certificate.
+func verifyCertSignature(alg int64, cert *x509.Certificate, data, sig []byte) error {
+	if !verifySignature(alg, cert.PublicKey, data, sig) {
+		return fmt.Errorf("%w: bad attestation signature", ErrInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+// Package webauthn verifies passkey (WebAuthn Level 2) registrations and
+// sign-ins on the server. Attestation formats "none" and "packed" are
+// accepted and keys may be ES256, EdDSA or RS256; attestation certificate
+// chains are not checked against vendor roots.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid     = errors.New("passkey response could not be verified")
+	ErrUnsupported = errors.New("this kind of passkey is not supported")
+)
+
+// Ceremony types in client data.
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+// Bytes is binary data that travels as unpadded base64url in JSON, as in the
+// WebAuthn JSON encoding.
+type Bytes []byte
+
+func (b Bytes) String() string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (b Bytes) MarshalJSON() ([]byte, error) { return json.Marshal(b.String()) }
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return errors.New("must be base64url")
+	}
+	*b = v
+	return nil
+}
+
+// RelyingParty identifies this service to authenticators. ID is the domain
+// passkeys are bound to and Origins lists the pages ceremonies may run on.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// User is the account a passkey is created for; ID is an opaque handle the
+// authenticator returns on sign-in.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential is a newly registered passkey.
+type Credential struct {
+	ID          []byte
+	PublicKey   []byte // COSE_Key
+	SignCount   uint32
+	AAGUID      []byte // authenticator model
+	Attestation string // attestation format
+}
+
+// EntityJSON names the relying party or the user in creation options.
+type EntityJSON struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// UserEntityJSON is the user in creation options.
+type UserEntityJSON struct {
+	ID          Bytes  `json:"id" swaggertype:"string"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   Bytes  `json:"id" swaggertype:"string"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptionsJSON, ready for
+// PublicKeyCredential.parseCreationOptionsFromJSON.
+type CreationOptions struct {
+	Challenge              Bytes                  `json:"challenge" swaggertype:"string"`
+	RP                     EntityJSON             `json:"rp"`
+	User                   UserEntityJSON         `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"` // milliseconds
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptionsJSON, ready for
+// PublicKeyCredential.parseRequestOptionsFromJSON. Without allowCredentials
+// the browser offers every passkey it holds for the relying party.
+type RequestOptions struct {
+	Challenge        Bytes  `json:"challenge" swaggertype:"string"`
+	Timeout          int64  `json:"timeout"` // milliseconds
+	RPID             string `json:"rpId"`
+	UserVerification string `json:"userVerification"`
+}
+
+// NewChallenge returns a fresh random challenge.
+func NewChallenge() ([]byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generate challenge: %w", err)
+	}
+	return b, nil
+}
+
+// CreationOptions asks for a discoverable, user-verified passkey for u,
+// skipping authenticators that already hold one of exclude.
+func (rp RelyingParty) CreationOptions(challenge []byte, u User, timeout time.Duration, exclude [][]byte) CreationOptions {
+	params := make([]CredentialParameter, len(SupportedAlgorithms))
+	for i, alg := range SupportedAlgorithms {
+		params[i] = CredentialParameter{Type: "public-key", Alg: alg}
+	}
+	excluded := make([]CredentialDescriptor, len(exclude))
+	for i, id := range exclude {
+		excluded[i] = CredentialDescriptor{Type: "public-key", ID: id}
+	}
+	return CreationOptions{
+		Challenge:              challenge,
+		RP:                     EntityJSON{ID: rp.ID, Name: rp.Name},
+		User:                   UserEntityJSON{ID: u.ID, Name: u.Name, DisplayName: u.DisplayName},
+		PubKeyCredParams:       params,
+		Timeout:                timeout.Milliseconds(),
+		ExcludeCredentials:     excluded,
+		AuthenticatorSelection: AuthenticatorSelection{ResidentKey: "required", UserVerification: "required"},
+		Attestation:            "none",
+	}
+}
+
+// RequestOptions asks for a user-verified assertion from any passkey of the
+// relying party.
+func (rp RelyingParty) RequestOptions(challenge []byte, timeout time.Duration) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          timeout.Milliseconds(),
+		RPID:             rp.ID,
+		UserVerification: "required",
+	}
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// Challenge returns the challenge clientDataJSON answers, so the caller can
+// find the ceremony it belongs to. It is not verified yet.
+func Challenge(clientDataJSON []byte) ([]byte, error) {
+	var cd clientData
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return nil, fmt.Errorf("%w: client data: %v", ErrInvalid, err)
+	}
+	c, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || len(c) == 0 {
+		return nil, fmt.Errorf("%w: client data has no challenge", ErrInvalid)
+	}
+	return c, nil
+}
+
+func (rp RelyingParty) checkClientData(raw []byte, typ string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: client data: %v", ErrInvalid, err)
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("%w: client data type %q", ErrInvalid, cd.Type)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalid)
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) || cd.CrossOrigin {
+		return fmt.Errorf("%w: origin %q not allowed", ErrInvalid, cd.Origin)
+	}
+	return nil
+}
+
+type authData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+	key          publicKey
+}
+
+func parseAuthData(b []byte) (authData, error) {
+	if len(b) < 37 {
+		return authData{}, fmt.Errorf("%w: authenticator data too short", ErrInvalid)
+	}
+	a := authData{rpIDHash: b[:32], flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37])}
+	rest := b[37:]
+	if a.flags&flagAttested != 0 {
+		if len(rest) < 18 {
+			return authData{}, fmt.Errorf("%w: attested credential data too short", ErrInvalid)
+		}
+		a.aaguid = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if n == 0 || n > 1023 || len(rest) < n {
+			return authData{}, fmt.Errorf("%w: bad credential id length", ErrInvalid)
+		}
+		a.credentialID, rest = rest[:n], rest[n:]
+		key, after, err := parsePublicKey(rest)
+		if err != nil {
+			return authData{}, err
+		}
+		a.key, a.publicKey, rest = key, rest[:len(rest)-len(after)], after
+	}
+	if a.flags&flagExtensions != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return authData{}, fmt.Errorf("%w: extensions: %v", ErrInvalid, err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return authData{}, fmt.Errorf("%w: trailing authenticator data", ErrInvalid)
+	}
+	return a, nil
+}
+
+func (rp RelyingParty) checkAuthData(a authData) error {
+	want := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(a.rpIDHash, want[:]) != 1 {
+		return fmt.Errorf("%w: passkey belongs to another relying party", ErrInvalid)
+	}
+	if a.flags&flagUserPresent == 0 || a.flags&flagUserVerified == 0 {
+		return fmt.Errorf("%w: user not present and verified", ErrInvalid)
+	}
+	return nil
+}
+
+// VerifyRegistration checks the response to CreationOptions with challenge
+// and returns the new credential.
+func (rp RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (Credential, error) {
+	if err := rp.checkClientData(clientDataJSON, typeCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+	item, rest, err := decodeCBOR(attestationObject)
+	if err != nil || len(rest) != 0 {
+		return Credential{}, fmt.Errorf("%w: attestation object: %v", ErrInvalid, err)
+	}
+	obj, _ := item.(map[any]any)
+	format, _ := obj["fmt"].(string)
+	stmt, okStmt := obj["attStmt"].(map[any]any)
+	rawAuthData, okData := obj["authData"].([]byte)
+	if format == "" || !okStmt || !okData {
+		return Credential{}, fmt.Errorf("%w: incomplete attestation object", ErrInvalid)
+	}
+	a, err := parseAuthData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if a.flags&flagAttested == 0 {
+		return Credential{}, fmt.Errorf("%w: no credential in attestation", ErrInvalid)
+	}
+	if err := rp.checkAuthData(a); err != nil {
+		return Credential{}, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := verifyAttestation(format, stmt, rawAuthData, clientDataHash[:], a.key); err != nil {
+		return Credential{}, err
+	}
+	return Credential{
+		ID:          bytes.Clone(a.credentialID),
+		PublicKey:   bytes.Clone(a.publicKey),
+		SignCount:   a.signCount,
+		AAGUID:      bytes.Clone(a.aaguid),
+		Attestation: format,
+	}, nil
+}
+
+// verifyAttestation checks the attestation statement over authData and the
+// client data hash.
+func verifyAttestation(format string, stmt map[any]any, authData, clientDataHash []byte, key publicKey) error {
+	switch format {
+	case "none":
+		if len(stmt) != 0 {
+			return fmt.Errorf("%w: none attestation with a statement", ErrInvalid)
+		}
+		return nil
+	case "packed":
+		alg, _ := stmt["alg"].(int64)
+		sig, _ := stmt["sig"].([]byte)
+		if len(sig) == 0 {
+			return fmt.Errorf("%w: packed attestation without signature", ErrInvalid)
+		}
+		signed := slices.Concat(authData, clientDataHash)
+		chain, ok := stmt["x5c"].([]any)
+		if !ok {
+			// Self attestation: signed with the credential key itself
+			if alg != key.alg {
+				return fmt.Errorf("%w: self attestation algorithm mismatch", ErrInvalid)
+			}
+			return key.verify(signed, sig)
+		}
+		if len(chain) == 0 {
+			return fmt.Errorf("%w: empty attestation certificate chain", ErrInvalid)
+		}
+		der, _ := chain[0].([]byte)
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("%w: attestation certificate: %v", ErrInvalid, err)
+		}
+		if cert.Version != 3 || cert.IsCA {
+			return fmt.Errorf("%w: attestation certificate must be a v3 leaf", ErrInvalid)
+		}
+		return verifyCertSignature(alg, cert, signed, sig)
+	}
+	return fmt.Errorf("%w: attestation format %q", ErrUnsupported, format)
+}
+
+// VerifyAssertion checks a sign-in answering challenge with the passkey
+// whose COSE public key and signature counter are stored, and returns the
+// new counter.
+func (rp RelyingParty) VerifyAssertion(challenge, publicKeyCOSE []byte, signCount uint32, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	if err := rp.checkClientData(clientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+	a, err := parseAuthData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.checkAuthData(a); err != nil {
+		return 0, err
+	}
+	key, _, err := parsePublicKey(publicKeyCOSE)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := key.verify(slices.Concat(authenticatorData, clientDataHash[:]), signature); err != nil {
+		return 0, err
+	}
+	// Authenticators that count must always count up; a repeat means a copy
+	if (a.signCount != 0 || signCount != 0) && a.signCount <= signCount {
+		return 0, fmt.Errorf("%w: signature counter did not increase, the passkey may be cloned", ErrInvalid)
+	}
+	return a.signCount, nil
+}
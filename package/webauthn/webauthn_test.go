@@ -0,0 +1,341 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testRP = RelyingParty{ID: "example.org", Name: "Dekamond", Origins: []string{"https://example.org"}}
+
+// Minimal CBOR encoding for building authenticator output.
+
+func cborHead(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+}
+
+func cborInt(v int) []byte {
+	if v >= 0 {
+		return cborHead(0, v)
+	}
+	return cborHead(1, -1-v)
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, len(b)), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, len(s)), s...) }
+
+// authenticator is a software passkey holding one ES256 credential.
+type authenticator struct {
+	key   *ecdsa.PrivateKey
+	id    []byte
+	count uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &authenticator{key: key, id: id}
+}
+
+// cose is the credential's public key as a COSE_Key.
+func (a *authenticator) cose() []byte {
+	b := []byte{0xa5}
+	b = append(b, cborInt(coseKty)...)
+	b = append(b, cborInt(ktyEC2)...)
+	b = append(b, cborInt(coseAlg)...)
+	b = append(b, cborInt(AlgES256)...)
+	b = append(b, cborInt(-1)...)
+	b = append(b, cborInt(crvP256)...)
+	b = append(b, cborInt(-2)...)
+	b = append(b, cborBytes(a.key.X.FillBytes(make([]byte, 32)))...)
+	b = append(b, cborInt(-3)...)
+	b = append(b, cborBytes(a.key.Y.FillBytes(make([]byte, 32)))...)
+	return b
+}
+
+func (a *authenticator) sign(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func authenticatorData(rpID string, flags byte, count uint32, attested []byte) []byte {
+	h := sha256.Sum256([]byte(rpID))
+	b := append(h[:], flags)
+	b = binary.BigEndian.AppendUint32(b, count)
+	return append(b, attested...)
+}
+
+func clientDataJSON(typ string, challenge []byte, origin string) []byte {
+	b, _ := json.Marshal(clientData{Type: typ, Challenge: base64.RawURLEncoding.EncodeToString(challenge), Origin: origin})
+	return b
+}
+
+// ceremony is what the browser and authenticator produce for one request;
+// tests tamper with it before it is verified.
+type ceremony struct {
+	rpID      string
+	origin    string
+	typ       string
+	flags     byte
+	format    string // attestation format, for registration
+	alg       int    // attestation statement algorithm, for packed
+	challenge []byte
+}
+
+func defaultCeremony(typ string, challenge []byte) ceremony {
+	return ceremony{
+		rpID:      testRP.ID,
+		origin:    testRP.Origins[0],
+		typ:       typ,
+		flags:     flagUserPresent | flagUserVerified,
+		format:    "none",
+		alg:       AlgES256,
+		challenge: challenge,
+	}
+}
+
+// create answers a registration: client data JSON and attestation object.
+func (a *authenticator) create(c ceremony) (cd, attObj []byte) {
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, a.cose()...)
+	ad := authenticatorData(c.rpID, c.flags|flagAttested, a.count, attested)
+	cd = clientDataJSON(c.typ, c.challenge, c.origin)
+
+	attObj = []byte{0xa3}
+	attObj = append(attObj, cborText("fmt")...)
+	attObj = append(attObj, cborText(c.format)...)
+	attObj = append(attObj, cborText("attStmt")...)
+	if c.format == "packed" {
+		hash := sha256.Sum256(cd)
+		attObj = append(attObj, 0xa2)
+		attObj = append(attObj, cborText("alg")...)
+		attObj = append(attObj, cborInt(c.alg)...)
+		attObj = append(attObj, cborText("sig")...)
+		attObj = append(attObj, cborBytes(a.sign(slices.Concat(ad, hash[:])))...)
+	} else {
+		attObj = append(attObj, 0xa0)
+	}
+	attObj = append(attObj, cborText("authData")...)
+	attObj = append(attObj, cborBytes(ad)...)
+	return cd, attObj
+}
+
+// get answers a sign-in: client data JSON, authenticator data and signature.
+func (a *authenticator) get(c ceremony) (cd, ad, sig []byte) {
+	ad = authenticatorData(c.rpID, c.flags, a.count, nil)
+	cd = clientDataJSON(c.typ, c.challenge, c.origin)
+	hash := sha256.Sum256(cd)
+	return cd, ad, a.sign(slices.Concat(ad, hash[:]))
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(c *ceremony)
+		wantErr error
+	}{
+		{"none attestation", func(c *ceremony) {}, nil},
+		{"packed self attestation", func(c *ceremony) { c.format = "packed" }, nil},
+		{"packed with another algorithm", func(c *ceremony) { c.format, c.alg = "packed", AlgRS256 }, ErrInvalid},
+		{"unknown attestation format", func(c *ceremony) { c.format = "tpm" }, ErrUnsupported},
+		{"other challenge", func(c *ceremony) { c.challenge = []byte("another challenge") }, ErrInvalid},
+		{"other origin", func(c *ceremony) { c.origin = "https://evil.example" }, ErrInvalid},
+		{"other relying party", func(c *ceremony) { c.rpID = "evil.example" }, ErrInvalid},
+		{"sign-in instead of registration", func(c *ceremony) { c.typ = typeGet }, ErrInvalid},
+		{"user not verified", func(c *ceremony) { c.flags = flagUserPresent }, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge, err := NewChallenge()
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth := newAuthenticator(t)
+			c := defaultCeremony(typeCreate, challenge)
+			tt.tamper(&c)
+			cd, attObj := auth.create(c)
+
+			cred, err := testRP.VerifyRegistration(challenge, cd, attObj)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyRegistration() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(cred.ID, auth.id) || !bytes.Equal(cred.PublicKey, auth.cose()) || cred.Attestation != c.format {
+				t.Errorf("credential = %+v, want the authenticator's", cred)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    uint32 // counter saved at the last sign-in
+		count     uint32 // counter the authenticator reports
+		tamper    func(c *ceremony)
+		wantCount uint32
+		wantErr   error
+	}{
+		{"counter increases", 4, 5, func(c *ceremony) {}, 5, nil},
+		{"authenticator without a counter", 0, 0, func(c *ceremony) {}, 0, nil},
+		{"repeated counter means a clone", 5, 5, func(c *ceremony) {}, 0, ErrInvalid},
+		{"counter went back", 5, 3, func(c *ceremony) {}, 0, ErrInvalid},
+		{"counter stopped", 5, 0, func(c *ceremony) {}, 0, ErrInvalid},
+		{"other challenge", 0, 1, func(c *ceremony) { c.challenge = []byte("another challenge") }, 0, ErrInvalid},
+		{"registration instead of sign-in", 0, 1, func(c *ceremony) { c.typ = typeCreate }, 0, ErrInvalid},
+		{"other relying party", 0, 1, func(c *ceremony) { c.rpID = "evil.example" }, 0, ErrInvalid},
+		{"user not present", 0, 1, func(c *ceremony) { c.flags = flagUserVerified }, 0, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge, err := NewChallenge()
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth := newAuthenticator(t)
+			auth.count = tt.count
+			c := defaultCeremony(typeGet, challenge)
+			tt.tamper(&c)
+			cd, ad, sig := auth.get(c)
+
+			got, err := testRP.VerifyAssertion(challenge, auth.cose(), tt.stored, cd, ad, sig)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAssertion() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.wantCount {
+				t.Errorf("VerifyAssertion() count = %d, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionSignature(t *testing.T) {
+	challenge, _ := NewChallenge()
+	auth, other := newAuthenticator(t), newAuthenticator(t)
+	cd, ad, sig := auth.get(defaultCeremony(typeGet, challenge))
+
+	if _, err := testRP.VerifyAssertion(challenge, other.cose(), 0, cd, ad, sig); !errors.Is(err, ErrInvalid) {
+		t.Errorf("signature by another key: error = %v, want %v", err, ErrInvalid)
+	}
+	ad[len(ad)-1]++ // counter 0 -> 1, no longer what was signed
+	if _, err := testRP.VerifyAssertion(challenge, auth.cose(), 0, cd, ad, sig); !errors.Is(err, ErrInvalid) {
+		t.Errorf("tampered authenticator data: error = %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// Valid items are from RFC 8949 appendix A.
+	tests := []struct {
+		name     string
+		hex      string
+		want     any
+		wantRest string
+		wantErr  bool
+	}{
+		{"small int", "17", int64(23), "", false},
+		{"uint16", "1903e8", int64(1000), "", false},
+		{"negative", "3903e7", int64(-1000), "", false},
+		{"bytes", "4401020304", []byte{1, 2, 3, 4}, "", false},
+		{"text", "6449455446", "IETF", "", false},
+		{"array", "83010203", []any{int64(1), int64(2), int64(3)}, "", false},
+		{"map", "a201020304", map[any]any{int64(1): int64(2), int64(3): int64(4)}, "", false},
+		{"text keys", "a26161016162820203", map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}, "", false},
+		{"bool", "f5", true, "", false},
+		{"double", "fb3ff199999999999a", 1.1, "", false},
+		{"tag is skipped", "c11a514b67b0", int64(1363896240), "", false},
+		{"trailing bytes returned", "0102", int64(1), "02", false},
+		{"empty", "", nil, "", true},
+		{"truncated string", "6449", nil, "", true},
+		{"truncated argument", "19", nil, "", true},
+		{"indefinite length", "5f42010243030405ff", nil, "", true},
+		{"integer overflow", "1bffffffffffffffff", nil, "", true},
+		{"array longer than input", "9affffffff00", nil, "", true},
+		{"duplicate map key", "a201020103", nil, "", true},
+		{"unsupported map key", "a1f501", nil, "", true},
+		{"too deep", strings.Repeat("81", maxCBORDepth+1) + "00", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, rest, err := decodeCBOR(in)
+			if tt.wantErr {
+				if !errors.Is(err, errCBOR) {
+					t.Fatalf("decodeCBOR() error = %v, want %v", err, errCBOR)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeCBOR() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || hex.EncodeToString(rest) != tt.wantRest {
+				t.Errorf("decodeCBOR() = %#v, rest %x; want %#v, rest %s", got, rest, tt.want, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	valid := newAuthenticator(t).cose()
+	offCurve := bytes.Clone(valid)
+	offCurve[len(offCurve)-1] ^= 1 // last byte of y
+
+	tests := []struct {
+		name    string
+		key     []byte
+		wantErr error
+	}{
+		{"ES256", valid, nil},
+		{"point off the curve", offCurve, ErrInvalid},
+		{"not a map", cborInt(1), ErrInvalid},
+		{"unsupported algorithm", slices.Concat([]byte{0xa2}, cborInt(coseKty), cborInt(ktyEC2), cborInt(coseAlg), cborInt(-35)), ErrUnsupported},
+		{"short Ed25519 key", slices.Concat([]byte{0xa4}, cborInt(coseKty), cborInt(ktyOKP), cborInt(coseAlg), cborInt(AlgEdDSA),
+			cborInt(-1), cborInt(crvEd25519), cborInt(-2), cborBytes(make([]byte, 16))), ErrInvalid},
+		{"short RSA key", slices.Concat([]byte{0xa4}, cborInt(coseKty), cborInt(ktyRSA), cborInt(coseAlg), cborInt(AlgRS256),
+			cborInt(-1), cborBytes(make([]byte, 128)), cborInt(-2), cborBytes([]byte{1, 0, 1})), ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, rest, err := parsePublicKey(tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parsePublicKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (key.alg != AlgES256 || len(rest) != 0) {
+				t.Errorf("parsePublicKey() = alg %d, %d trailing bytes", key.alg, len(rest))
+			}
+		})
+	}
+}
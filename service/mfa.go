@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"dekamond-task/package/health"
+	"dekamond-task/package/jwt"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/metrics"
+	"dekamond-task/package/schedule"
+	"dekamond-task/package/totp"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANoEnrollment     = errors.New("no pending two-factor enrollment")
+	ErrMFAInvalidCode      = errors.New("invalid authentication code")
+	ErrMFAChallengeExpired = errors.New("sign-in challenge expired or not found")
+	ErrMFALocked           = errors.New("too many failed authentication codes; try again later")
+)
+
+// MFAPolicy tunes the second factor. After MaxAttempts wrong codes in a row
+// the factor refuses codes for ChallengeTTL.
+type MFAPolicy struct {
+	Issuer        string
+	ChallengeTTL  time.Duration
+	MaxAttempts   int
+	RecoveryCodes int
+}
+
+// totpFactor is a user's authenticator app. It only guards sign-ins once
+// confirmed.
+type totpFactor struct {
+	secret      string
+	confirmed   bool
+	lastStep    int64               // last accepted step, so a code can't be replayed
+	recovery    [][sha256.Size]byte // hashes of the unused recovery codes
+	failures    int
+	lockedUntil time.Time
+}
+
+// challenge is a sign-in waiting for its second factor.
+type challenge struct {
+	user    string
+	expires time.Time
+	stepUp  bool // completed with a code sent to the user instead of one from the app
+}
+
+// CodeChecker checks one-time codes sent to a user, which complete step-up
+// challenges.
+type CodeChecker interface {
+	ValidateOTP(ctx context.Context, to, code string) error
+}
+
+// MFAService keeps users' TOTP factors and pending second-factor challenges.
+type MFAService struct {
+	mu         sync.Mutex
+	log        *slog.Logger
+	policy     MFAPolicy
+	codes      CodeChecker
+	factors    map[string]*totpFactor // user -> factor
+	challenges map[string]challenge   // challenge id -> pending sign-in
+}
+
+func NewMFAService(p MFAPolicy, codes CodeChecker, log *slog.Logger) *MFAService {
+	return &MFAService{
+		log:        log,
+		policy:     p,
+		codes:      codes,
+		factors:    make(map[string]*totpFactor),
+		challenges: make(map[string]challenge),
+	}
+}
+
+// SetPolicy swaps the policy; open challenges keep their expiry.
+func (m *MFAService) SetPolicy(p MFAPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = p
+}
+
+// Enabled reports whether user must pass a second factor to sign in.
+func (m *MFAService) Enabled(user string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.factors[user]
+	return ok && f.confirmed
+}
+
+// Enroll starts, or restarts, TOTP enrollment for user and returns the secret
+// and its provisioning URI. Sign-ins need no code until Confirm succeeds.
+func (m *MFAService) Enroll(ctx context.Context, user string) (secret, uri string, err error) {
+	_, span := tracer.Start(ctx, "MFAService.Enroll")
+	defer span.End()
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.factors[user]; ok && f.confirmed {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+	m.factors[user] = &totpFactor{secret: secret}
+	return secret, totp.URI(secret, m.policy.Issuer, user), nil
+}
+
+// Confirm turns on the pending factor once the app produces a valid code, and
+// returns the user's recovery codes. They are only shown this once.
+func (m *MFAService) Confirm(ctx context.Context, user, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.Confirm")
+	defer span.End()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.factors[user]
+	if !ok {
+		return nil, ErrMFANoEnrollment
+	}
+	if f.confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := m.check(f, code, ""); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	recovery, err := m.newRecoveryCodes(f)
+	if err != nil {
+		return nil, err
+	}
+	f.confirmed = true
+	logger.FromContext(ctx, m.log).Info("two-factor authentication enabled", "user", user)
+	return recovery, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of user after checking
+// a code from the app.
+func (m *MFAService) RegenerateRecoveryCodes(ctx context.Context, user, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.factors[user]
+	if !ok || !f.confirmed {
+		return nil, ErrMFANotEnabled
+	}
+	if err := m.check(f, code, ""); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	logger.FromContext(ctx, m.log).Info("recovery codes regenerated", "user", user)
+	return m.newRecoveryCodes(f)
+}
+
+// Disable removes the factor of user after checking a code from the app or a
+// recovery code.
+func (m *MFAService) Disable(ctx context.Context, user, code, recovery string) error {
+	ctx, span := tracer.Start(ctx, "MFAService.Disable")
+	defer span.End()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.factors[user]
+	if !ok || !f.confirmed {
+		return ErrMFANotEnabled
+	}
+	if err := m.check(f, code, recovery); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	delete(m.factors, user)
+	logger.FromContext(ctx, m.log).Info("two-factor authentication disabled", "user", user)
+	return nil
+}
+
+// Challenge opens a second-factor challenge for user, who has passed the
+// first factor, and returns its token.
+func (m *MFAService) Challenge(ctx context.Context, user string) (string, error) {
+	_, span := tracer.Start(ctx, "MFAService.Challenge")
+	defer span.End()
+	return m.open(user, false)
+}
+
+// StepUp opens a challenge for a risky sign-in by user, who has no
+// authenticator app; it is completed with a fresh code the caller sends them.
+func (m *MFAService) StepUp(ctx context.Context, user string) (string, error) {
+	_, span := tracer.Start(ctx, "MFAService.StepUp")
+	defer span.End()
+	return m.open(user, true)
+}
+
+func (m *MFAService) open(user string, stepUp bool) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate challenge: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, err := jwt.CreateChallengeToken(user, id, m.policy.ChallengeTTL)
+	if err != nil {
+		return "", err
+	}
+	m.challenges[id] = challenge{user: user, expires: time.Now().Add(m.policy.ChallengeTTL), stepUp: stepUp}
+	return token, nil
+}
+
+// CompleteChallenge checks a code from the app, or a recovery code, against
+// the challenge in token and returns the user it signs in. Step-up
+// challenges take the code sent to the user instead, and are reported as
+// such. A challenge can be completed once.
+func (m *MFAService) CompleteChallenge(ctx context.Context, token, code, recovery string) (user string, stepUp bool, err error) {
+	ctx, span := tracer.Start(ctx, "MFAService.CompleteChallenge")
+	defer span.End()
+
+	user, id, err := jwt.ValidateChallengeToken(token)
+	if err != nil {
+		return "", false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.challenges[id]
+	if !ok || c.user != user || time.Now().After(c.expires) {
+		return "", false, ErrMFAChallengeExpired
+	}
+	if c.stepUp {
+		if recovery != "" {
+			return "", false, ErrMFAInvalidCode
+		}
+		// The code store counts the attempts and locks the code
+		if err := m.codes.ValidateOTP(ctx, user, code); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return "", false, err
+		}
+		delete(m.challenges, id)
+		return user, true, nil
+	}
+	f, ok := m.factors[user]
+	if !ok || !f.confirmed {
+		// Turned off since the challenge was issued
+		delete(m.challenges, id)
+		return "", false, ErrMFAChallengeExpired
+	}
+	if err := m.check(f, code, recovery); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		if errors.Is(err, ErrMFALocked) {
+			delete(m.challenges, id)
+			logger.FromContext(ctx, m.log).Warn("second factor locked after too many failed codes", "user", user)
+		}
+		return "", false, err
+	}
+	delete(m.challenges, id)
+	if recovery != "" {
+		logger.FromContext(ctx, m.log).Warn("signed in with a recovery code", "user", user, "recovery_codes_left", len(f.recovery))
+	}
+	return user, false, nil
+}
+
+// check verifies a TOTP code, or else a recovery code, which is used up.
+// Callers hold m.mu.
+func (m *MFAService) check(f *totpFactor, code, recovery string) error {
+	method := "totp"
+	if recovery != "" {
+		method = "recovery"
+	}
+	now := time.Now()
+	if now.Before(f.lockedUntil) {
+		metrics.MFAVerifications.WithLabelValues(method, metrics.OutcomeLocked).Inc()
+		return ErrMFALocked
+	}
+
+	ok := false
+	if recovery != "" {
+		ok = f.useRecoveryCode(recovery)
+	} else if step, valid := totp.Verify(f.secret, code, now); valid && step > f.lastStep {
+		f.lastStep = step
+		ok = true
+	}
+	if !ok {
+		f.failures++
+		if f.failures >= m.policy.MaxAttempts {
+			f.failures = 0
+			f.lockedUntil = now.Add(m.policy.ChallengeTTL)
+			metrics.MFAVerifications.WithLabelValues(method, metrics.OutcomeLocked).Inc()
+			return ErrMFALocked
+		}
+		metrics.MFAVerifications.WithLabelValues(method, metrics.OutcomeWrong).Inc()
+		return ErrMFAInvalidCode
+	}
+	f.failures = 0
+	metrics.MFAVerifications.WithLabelValues(method, metrics.OutcomeOK).Inc()
+	return nil
+}
+
+// useRecoveryCode consumes code if it is one of f's unused recovery codes.
+func (f *totpFactor) useRecoveryCode(code string) bool {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	for i, h := range f.recovery {
+		if subtle.ConstantTimeCompare(h[:], sum[:]) == 1 {
+			f.recovery = append(f.recovery[:i], f.recovery[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes replaces f's recovery codes and returns them in the clear;
+// only their hashes are kept. Callers hold m.mu.
+func (m *MFAService) newRecoveryCodes(f *totpFactor) ([]string, error) {
+	plain := make([]string, m.policy.RecoveryCodes)
+	hashes := make([][sha256.Size]byte, m.policy.RecoveryCodes)
+	for i := range plain {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate recovery codes: %w", err)
+		}
+		c := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		plain[i] = c[:5] + "-" + c[5:]
+		hashes[i] = sha256.Sum256([]byte(c))
+	}
+	f.recovery = hashes
+	return plain, nil
+}
+
+// normalizeRecoveryCode accepts codes typed in any case, with or without
+// the dash.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// Ping reports whether the store can be locked before ctx is done.
+func (m *MFAService) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, m.mu.TryLock); err != nil {
+		return err
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// RunSweeper drops expired challenges every interval until ctx is done.
+func (m *MFAService) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, m.sweep)
+}
+
+func (m *MFAService) sweep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, c := range m.challenges {
+		if now.After(c.expires) {
+			delete(m.challenges, id)
+		}
+	}
+}
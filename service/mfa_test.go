@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+var errWrongCode = errors.New("wrong code")
+
+// sentCodes stands in for the OTP store, accepting code for every user.
+type sentCodes struct {
+	code  string
+	asked []string
+}
+
+func (s *sentCodes) ValidateOTP(_ context.Context, to, code string) error {
+	s.asked = append(s.asked, to)
+	if code != s.code {
+		return errWrongCode
+	}
+	return nil
+}
+
+func TestCompleteStepUpChallenge(t *testing.T) {
+	const user = "09123456789"
+	tests := []struct {
+		name       string
+		codes      []string // submitted in turn
+		recovery   string
+		wantErrs   []error
+		wantStepUp bool
+	}{
+		{"sent code signs in", []string{"54321"}, "", []error{nil}, true},
+		{"wrong code can be retried", []string{"00000", "54321"}, "", []error{errWrongCode, nil}, true},
+		{"challenge works once", []string{"54321", "54321"}, "", []error{nil, ErrMFAChallengeExpired}, true},
+		{"recovery codes do not apply", []string{""}, "k3x9q-7hvzt", []error{ErrMFAInvalidCode}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := &sentCodes{code: "54321"}
+			m := NewMFAService(MFAPolicy{ChallengeTTL: time.Minute, MaxAttempts: 3}, codes, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			token, err := m.StepUp(context.Background(), user)
+			if err != nil {
+				t.Fatalf("StepUp: %v", err)
+			}
+			for i, code := range tt.codes {
+				got, stepUp, err := m.CompleteChallenge(context.Background(), token, code, tt.recovery)
+				if !errors.Is(err, tt.wantErrs[i]) {
+					t.Fatalf("attempt %d: got %v, want %v", i+1, err, tt.wantErrs[i])
+				}
+				if err == nil && (got != user || stepUp != tt.wantStepUp) {
+					t.Errorf("attempt %d: got %q, step-up %v; want %q, %v", i+1, got, stepUp, user, tt.wantStepUp)
+				}
+			}
+			for _, to := range codes.asked {
+				if to != user {
+					t.Errorf("code checked for %q, want %q", to, user)
+				}
+			}
+		})
+	}
+}
+
+func TestAppChallengeIsNotAStepUp(t *testing.T) {
+	m := NewMFAService(MFAPolicy{ChallengeTTL: time.Minute, MaxAttempts: 3}, &sentCodes{code: "54321"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	token, err := m.Challenge(context.Background(), "09123456789")
+	if err != nil {
+		t.Fatalf("Challenge: %v", err)
+	}
+	// The user has no authenticator app, so a sent code must not complete it
+	if _, _, err := m.CompleteChallenge(context.Background(), token, "54321", ""); !errors.Is(err, ErrMFAChallengeExpired) {
+		t.Fatalf("got %v, want %v", err, ErrMFAChallengeExpired)
+	}
+}
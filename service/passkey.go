@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"dekamond-task/model"
+	"dekamond-task/package/health"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/schedule"
+	"dekamond-task/package/webauthn"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrPasskeyChallenge   = errors.New("passkey challenge expired or not found")
+	ErrPasskeyUnavailable = errors.New("too many passkey ceremonies in progress; try again shortly")
+)
+
+// PasskeyPolicy sets the relying party and how long a ceremony may take.
+type PasskeyPolicy struct {
+	RP         webauthn.RelyingParty
+	Timeout    time.Duration
+	MaxPending int
+}
+
+// ceremony is a registration or sign-in waiting for the authenticator.
+// Registrations belong to user; sign-ins have none until verified.
+type ceremony struct {
+	user    string
+	expires time.Time
+}
+
+// PasskeyService runs WebAuthn registration and sign-in. Passkeys are kept
+// on the users in UserService; open ceremonies are kept here, keyed by their
+// challenge, and each can be completed once.
+type PasskeyService struct {
+	mu      sync.Mutex
+	log     *slog.Logger
+	policy  PasskeyPolicy
+	users   *UserService
+	pending map[string]ceremony // base64url challenge -> ceremony
+}
+
+func NewPasskeyService(p PasskeyPolicy, users *UserService, log *slog.Logger) *PasskeyService {
+	return &PasskeyService{log: log, policy: p, users: users, pending: make(map[string]ceremony)}
+}
+
+// SetPolicy swaps the policy; open ceremonies keep their expiry.
+func (p *PasskeyService) SetPolicy(policy PasskeyPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+}
+
+// BeginRegistration opens a registration for user and returns the options to
+// pass to navigator.credentials.create.
+func (p *PasskeyService) BeginRegistration(ctx context.Context, user string) (webauthn.CreationOptions, error) {
+	_, span := tracer.Start(ctx, "PasskeyService.BeginRegistration")
+	defer span.End()
+
+	handle, err := p.users.PasskeyUserHandle(user)
+	if err != nil {
+		return webauthn.CreationOptions{}, err
+	}
+	usr, err := p.users.GetUser(user)
+	if err != nil {
+		return webauthn.CreationOptions{}, err
+	}
+	exclude := make([][]byte, 0, len(usr.Passkeys))
+	for _, pk := range usr.Passkeys {
+		if id, err := base64.RawURLEncoding.DecodeString(pk.ID); err == nil {
+			exclude = append(exclude, id)
+		}
+	}
+
+	challenge, policy, err := p.open(user)
+	if err != nil {
+		return webauthn.CreationOptions{}, err
+	}
+	u := webauthn.User{ID: handle, Name: user, DisplayName: user}
+	return policy.RP.CreationOptions(challenge, u, policy.Timeout, exclude), nil
+}
+
+// FinishRegistration verifies the authenticator's response for user and
+// stores the new passkey under name.
+func (p *PasskeyService) FinishRegistration(ctx context.Context, user, name string, clientDataJSON, attestationObject []byte) (model.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.FinishRegistration")
+	defer span.End()
+
+	challenge, policy, err := p.take(clientDataJSON, user)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return model.Passkey{}, err
+	}
+	cred, err := policy.RP.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		logger.FromContext(ctx, p.log).Info("passkey registration rejected", "user", user, "err", err)
+		return model.Passkey{}, err
+	}
+	now := time.Now()
+	pk := model.Passkey{
+		ID:         webauthn.Bytes(cred.ID).String(),
+		Name:       name,
+		PublicKey:  cred.PublicKey,
+		SignCount:  cred.SignCount,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if err := p.users.AddPasskey(user, pk); err != nil {
+		return model.Passkey{}, err
+	}
+	logger.FromContext(ctx, p.log).Info("passkey registered", "user", user, "passkey", pk.ID, "attestation", cred.Attestation)
+	return pk, nil
+}
+
+// BeginLogin opens a sign-in and returns the options to pass to
+// navigator.credentials.get.
+func (p *PasskeyService) BeginLogin(ctx context.Context) (webauthn.RequestOptions, error) {
+	_, span := tracer.Start(ctx, "PasskeyService.BeginLogin")
+	defer span.End()
+
+	challenge, policy, err := p.open("")
+	if err != nil {
+		return webauthn.RequestOptions{}, err
+	}
+	return policy.RP.RequestOptions(challenge, policy.Timeout), nil
+}
+
+// FinishLogin verifies an assertion and returns the user it signs in.
+// userHandle, when the authenticator sends one, must match the owner.
+func (p *PasskeyService) FinishLogin(ctx context.Context, credentialID, clientDataJSON, authenticatorData, signature, userHandle []byte) (model.User, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.FinishLogin")
+	defer span.End()
+
+	challenge, policy, err := p.take(clientDataJSON, "")
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return model.User{}, err
+	}
+	id := webauthn.Bytes(credentialID).String()
+	usr, pk, err := p.users.PasskeyOwner(id)
+	if err != nil {
+		// Removed from the account, or never registered here
+		return model.User{}, fmt.Errorf("%w: unknown passkey %s", webauthn.ErrInvalid, id)
+	}
+	if len(userHandle) > 0 && !bytes.Equal(userHandle, usr.PasskeyID) {
+		return model.User{}, fmt.Errorf("%w: user handle mismatch", webauthn.ErrInvalid)
+	}
+	signCount, err := policy.RP.VerifyAssertion(challenge, pk.PublicKey, pk.SignCount, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return model.User{}, fmt.Errorf("passkey %s of %s: %w", id, usr.ID(), err)
+	}
+	p.users.TouchPasskey(usr.ID(), id, signCount)
+	return usr, nil
+}
+
+// open records a new ceremony for user and returns its challenge with the
+// policy it runs under.
+func (p *PasskeyService) open(user string) ([]byte, PasskeyPolicy, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, PasskeyPolicy{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) >= p.policy.MaxPending {
+		return nil, PasskeyPolicy{}, ErrPasskeyUnavailable
+	}
+	p.pending[webauthn.Bytes(challenge).String()] = ceremony{user: user, expires: time.Now().Add(p.policy.Timeout)}
+	return challenge, p.policy, nil
+}
+
+// take ends the ceremony clientDataJSON answers, which must belong to user.
+func (p *PasskeyService) take(clientDataJSON []byte, user string) ([]byte, PasskeyPolicy, error) {
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return nil, PasskeyPolicy{}, err
+	}
+	key := webauthn.Bytes(challenge).String()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.pending[key]
+	if !ok || c.user != user || time.Now().After(c.expires) {
+		return nil, PasskeyPolicy{}, ErrPasskeyChallenge
+	}
+	delete(p.pending, key)
+	return challenge, p.policy, nil
+}
+
+// Ping reports whether the store can be locked before ctx is done.
+func (p *PasskeyService) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, p.mu.TryLock); err != nil {
+		return err
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// RunSweeper drops expired ceremonies every interval until ctx is done.
+func (p *PasskeyService) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, p.sweep)
+}
+
+func (p *PasskeyService) sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for key, c := range p.pending {
+		if now.After(c.expires) {
+			delete(p.pending, key)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"dekamond-task/model"
+	"dekamond-task/package/dispatch"
+	"dekamond-task/package/geoip"
+	"dekamond-task/package/health"
+	"dekamond-task/package/i18n"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/metrics"
+	otptemplate "dekamond-task/package/otp_template"
+	"dekamond-task/package/schedule"
+	"dekamond-task/package/useragent"
+)
+
+var (
+	ErrSignInBlocked = errors.New("this sign-in was blocked as suspicious")
+	ErrStepUpPasskey = errors.New("this sign-in looks unusual and needs a second factor; sign in with your passkey instead")
+)
+
+// Risk actions, from least to most severe.
+const (
+	RiskAllow  = "allow"
+	RiskStepUp = "step_up"
+	RiskBlock  = "block"
+)
+
+// Risk signals a sign-in can raise.
+const (
+	SignalNewDevice        = "new_device"
+	SignalNewCountry       = "new_country"
+	SignalNewASN           = "new_asn"
+	SignalImpossibleTravel = "impossible_travel"
+	SignalRapidRequests    = "rapid_requests"
+)
+
+// maxRemembered caps the devices, countries and networks kept per user; the
+// least recently used go first.
+const maxRemembered = 50
+
+// RiskPolicy weighs the signals of a sign-in. A score of StepUpScore or more
+// needs a second factor, or a second code for users without one; BlockScore
+// or more is refused.
+type RiskPolicy struct {
+	GeoIP           *geoip.DB      // nil turns off the country, network and travel signals
+	Weights         map[string]int // signal -> score
+	StepUpScore     int
+	BlockScore      int
+	TravelWindow    time.Duration // another country sooner than this after the last sign-in is impossible travel
+	RapidRequests   int           // OTP requests within RapidWindow that count as rapid
+	RapidWindow     time.Duration
+	Memory          time.Duration // devices, countries and networks unused this long are forgotten
+	NotifyNewDevice bool
+}
+
+// SignIn is a sign-in to assess or remember.
+type SignIn struct {
+	User      string
+	UserAgent string
+	IP        string
+	SteppedUp bool // passed a step-up with a second code; the user is always told
+}
+
+// Assessment is the verdict on a sign-in.
+type Assessment struct {
+	Score   int
+	Signals []string
+	Action  string
+}
+
+// riskProfile is what is known about where a user signs in from.
+type riskProfile struct {
+	devices     map[string]time.Time // fingerprint -> last sign-in
+	countries   map[string]time.Time
+	networks    map[uint32]time.Time // ASN -> last sign-in
+	lastCountry string
+	lastAt      time.Time
+}
+
+// RiskService scores sign-ins against each user's history and tells users
+// when a new device signs in to their account.
+type RiskService struct {
+	mu       sync.Mutex
+	log      *slog.Logger
+	policy   RiskPolicy
+	sender   *dispatch.Dispatcher
+	profiles map[string]*riskProfile
+	requests map[string][]time.Time // identifier -> recent OTP requests
+}
+
+func NewRiskService(p RiskPolicy, sender *dispatch.Dispatcher, log *slog.Logger) *RiskService {
+	return &RiskService{
+		log:      log,
+		policy:   p,
+		sender:   sender,
+		profiles: make(map[string]*riskProfile),
+		requests: make(map[string][]time.Time),
+	}
+}
+
+// SetPolicy swaps the policy; history is kept.
+func (s *RiskService) SetPolicy(p RiskPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+}
+
+// RecordOTPRequest notes an admitted OTP request for identifier. Requests
+// refused by the access rules, challenge or rate limit are not counted.
+func (s *RiskService) RecordOTPRequest(identifier string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	recent := s.recentRequests(identifier, now)
+	if n := len(recent); n > 0 && n >= s.policy.RapidRequests {
+		recent = recent[n-s.policy.RapidRequests+1:]
+	}
+	s.requests[identifier] = append(recent, now)
+}
+
+// Assess scores a sign-in that passed its first factor. A user's first
+// sign-in has no history to differ from.
+func (s *RiskService) Assess(ctx context.Context, in SignIn) Assessment {
+	_, span := tracer.Start(ctx, "RiskService.Assess")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var signals []string
+	if p := s.profiles[in.User]; p != nil {
+		if _, ok := p.devices[fingerprint(in.UserAgent)]; !ok {
+			signals = append(signals, SignalNewDevice)
+		}
+		if loc, ok := s.locate(in.IP); ok {
+			if _, seen := p.countries[loc.Country]; !seen && len(p.countries) > 0 {
+				signals = append(signals, SignalNewCountry)
+			}
+			if p.lastCountry != "" && p.lastCountry != loc.Country && now.Sub(p.lastAt) < s.policy.TravelWindow {
+				signals = append(signals, SignalImpossibleTravel)
+			}
+			if _, seen := p.networks[loc.ASN]; !seen && len(p.networks) > 0 {
+				signals = append(signals, SignalNewASN)
+			}
+		}
+	}
+	if len(s.recentRequests(in.User, now)) >= s.policy.RapidRequests {
+		signals = append(signals, SignalRapidRequests)
+	}
+
+	a := Assessment{Signals: signals, Action: RiskAllow}
+	for _, sig := range signals {
+		a.Score += s.policy.Weights[sig]
+		metrics.RiskSignals.WithLabelValues(sig).Inc()
+	}
+	switch {
+	case a.Score >= s.policy.BlockScore:
+		a.Action = RiskBlock
+	case a.Score >= s.policy.StepUpScore:
+		a.Action = RiskStepUp
+	}
+	metrics.RiskDecisions.WithLabelValues(a.Action).Inc()
+	if len(signals) > 0 {
+		level := slog.LevelInfo
+		if a.Action != RiskAllow {
+			level = slog.LevelWarn
+		}
+		logger.FromContext(ctx, s.log).Log(ctx, level, "sign-in risk assessed", "user", in.User, "ip", in.IP,
+			"signals", signals, "score", a.Score, "action", a.Action)
+	}
+	return a
+}
+
+// Remember adds a completed sign-in to the user's history. When it came from
+// a device the user had not signed in with before, or needed a step-up, the
+// user is told through the channel they sign in with, in locale.
+func (s *RiskService) Remember(ctx context.Context, in SignIn, locale string) {
+	s.mu.Lock()
+	now := time.Now()
+	p := s.profiles[in.User]
+	first := p == nil
+	if first {
+		p = &riskProfile{
+			devices:   make(map[string]time.Time),
+			countries: make(map[string]time.Time),
+			networks:  make(map[uint32]time.Time),
+		}
+		s.profiles[in.User] = p
+	}
+	fp := fingerprint(in.UserAgent)
+	_, known := p.devices[fp]
+	remember(p.devices, fp, now)
+	loc, located := s.locate(in.IP)
+	if located {
+		remember(p.countries, loc.Country, now)
+		remember(p.networks, loc.ASN, now)
+		p.lastCountry = loc.Country
+	}
+	p.lastAt = now
+	notify := !first && (!known && s.policy.NotifyNewDevice || in.SteppedUp)
+	s.mu.Unlock()
+
+	if notify {
+		where := in.IP
+		if located {
+			where += " (" + loc.Country + ")"
+		}
+		s.notifyNewDevice(ctx, in.User, useragent.Device(in.UserAgent), where, locale)
+	}
+}
+
+// notifyNewDevice queues the new-device warning. A failure is logged only;
+// the sign-in has already succeeded.
+func (s *RiskService) notifyNewDevice(ctx context.Context, user, device, where, locale string) {
+	log := logger.FromContext(ctx, s.log)
+	locale = i18n.Normalize(locale)
+	channel := otptemplate.ChannelSMS
+	if model.IsEmail(user) {
+		channel = otptemplate.ChannelEmail
+	}
+	if !s.sender.Serves(channel) {
+		log.Warn("new device notification not sent", "user", user, "err", dispatch.ErrNoProvider)
+		return
+	}
+	body := i18n.T(locale, "New sign-in to your account from %s at %s. If this wasn't you, sign it out under your sessions.", device, where)
+	if channel == otptemplate.ChannelEmail {
+		body = i18n.T(locale, "New sign-in to your account") + "\n\n" + body
+	}
+	id, err := s.sender.Enqueue(ctx, dispatch.Message{
+		Channel:   channel,
+		To:        user,
+		Body:      body,
+		Locale:    locale,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		log.Warn("new device notification not sent", "user", user, "err", err)
+		return
+	}
+	log.Info("new device notification queued", "user", user, "device", device, "delivery_id", id)
+}
+
+// locate looks ip up in the GeoIP database, if one is loaded; the caller
+// holds mu.
+func (s *RiskService) locate(ip string) (geoip.Location, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if s.policy.GeoIP == nil || err != nil {
+		return geoip.Location{}, false
+	}
+	return s.policy.GeoIP.Lookup(addr)
+}
+
+// recentRequests returns the OTP requests for identifier within the rapid
+// window; the caller holds mu.
+func (s *RiskService) recentRequests(identifier string, now time.Time) []time.Time {
+	times := s.requests[identifier]
+	for len(times) > 0 && now.Sub(times[0]) > s.policy.RapidWindow {
+		times = times[1:]
+	}
+	return times
+}
+
+// fingerprint identifies a device by its User-Agent, which is all a server
+// sees of it before the device holds a token.
+func fingerprint(userAgent string) string {
+	sum := sha256.Sum256([]byte(userAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// remember marks k used at now, forgetting the least recently used entry
+// when m is full.
+func remember[K comparable](m map[K]time.Time, k K, now time.Time) {
+	if _, ok := m[k]; !ok && len(m) >= maxRemembered {
+		var oldest K
+		var oldestAt time.Time
+		for key, at := range m {
+			if oldestAt.IsZero() || at.Before(oldestAt) {
+				oldest, oldestAt = key, at
+			}
+		}
+		delete(m, oldest)
+	}
+	m[k] = now
+}
+
+// Ping reports whether the store can be locked before ctx is done.
+func (s *RiskService) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, s.mu.TryLock); err != nil {
+		return err
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// RunSweeper forgets stale history every interval until ctx is done.
+func (s *RiskService) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, s.sweep)
+}
+
+func (s *RiskService) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id := range s.requests {
+		if recent := s.recentRequests(id, now); len(recent) > 0 {
+			s.requests[id] = recent
+		} else {
+			delete(s.requests, id)
+		}
+	}
+	cutoff := now.Add(-s.policy.Memory)
+	for user, p := range s.profiles {
+		if p.lastAt.Before(cutoff) {
+			delete(s.profiles, user)
+			continue
+		}
+		forget(p.devices, cutoff)
+		forget(p.countries, cutoff)
+		forget(p.networks, cutoff)
+	}
+}
+
+// forget drops the entries of m last used before cutoff.
+func forget[K comparable](m map[K]time.Time, cutoff time.Time) {
+	for k, at := range m {
+		if at.Before(cutoff) {
+			delete(m, k)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"dekamond-task/model"
+	"dekamond-task/package/health"
+	"dekamond-task/package/jwt"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/schedule"
+	"dekamond-task/package/useragent"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionEnded    = errors.New("session was signed out or has expired")
+)
+
+// maxUserAgent caps the User-Agent kept on a session.
+const maxUserAgent = 512
+
+// SessionPolicy sets how long a sign-in lasts, which is the access token
+// lifetime, and how many sign-ins a user may keep; past MaxPerUser the least
+// recently seen one is terminated.
+type SessionPolicy struct {
+	TTL        time.Duration
+	MaxPerUser int
+}
+
+// SessionService tracks the sign-ins of each user and issues their access
+// tokens. A token is only accepted while its session is live.
+type SessionService struct {
+	mu       sync.Mutex
+	log      *slog.Logger
+	policy   SessionPolicy
+	sessions map[string]*model.Session // session ID -> session
+	byUser   map[string][]string       // user -> session IDs, oldest first
+}
+
+func NewSessionService(p SessionPolicy, log *slog.Logger) *SessionService {
+	return &SessionService{
+		log:      log,
+		policy:   p,
+		sessions: make(map[string]*model.Session),
+		byUser:   make(map[string][]string),
+	}
+}
+
+// SetPolicy swaps the policy; live sessions keep their expiry.
+func (s *SessionService) SetPolicy(p SessionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+}
+
+// Start opens a session for user, who has just signed in from userAgent at
+// ip, and returns it with its access token.
+func (s *SessionService) Start(ctx context.Context, user, userAgent, ip string) (model.Session, string, error) {
+	_, span := tracer.Start(ctx, "SessionService.Start")
+	defer span.End()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return model.Session{}, "", fmt.Errorf("generate session id: %w", err)
+	}
+	id := hex.EncodeToString(b)
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+	token, err := jwt.CreateJWT(user, id)
+	if err != nil {
+		return model.Session{}, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	sess := &model.Session{
+		ID:         id,
+		User:       user,
+		Device:     useragent.Device(userAgent),
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.policy.TTL),
+	}
+	s.sessions[sess.ID] = sess
+	s.byUser[user] = append(s.byUser[user], sess.ID)
+	if ids := s.byUser[user]; len(ids) > s.policy.MaxPerUser {
+		evicted := slices.MinFunc(ids, func(a, b string) int {
+			return s.sessions[a].LastSeenAt.Compare(s.sessions[b].LastSeenAt)
+		})
+		s.remove(evicted)
+		logger.FromContext(ctx, s.log).Info("session limit reached, oldest signed out", "user", user, "session", evicted)
+	}
+	logger.FromContext(ctx, s.log).Info("session started", "user", user, "session", id, "device", sess.Device, "ip", ip)
+	return *sess, token, nil
+}
+
+// Touch records activity on session id of user. It fails with
+// ErrSessionEnded once the session was terminated or has expired.
+func (s *SessionService) Touch(user, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	now := time.Now()
+	if !ok || sess.User != user || now.After(sess.ExpiresAt) {
+		return ErrSessionEnded
+	}
+	sess.LastSeenAt = now
+	return nil
+}
+
+// List returns the live sessions of user, most recently seen first.
+func (s *SessionService) List(user string) []model.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	out := make([]model.Session, 0, len(s.byUser[user]))
+	for _, id := range s.byUser[user] {
+		if sess := s.sessions[id]; now.Before(sess.ExpiresAt) {
+			out = append(out, *sess)
+		}
+	}
+	slices.SortFunc(out, func(a, b model.Session) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+	return out
+}
+
+// Terminate signs out session id of user; its tokens stop working at once.
+func (s *SessionService) Terminate(ctx context.Context, user, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || sess.User != user {
+		return ErrSessionNotFound
+	}
+	s.remove(id)
+	logger.FromContext(ctx, s.log).Info("session terminated", "user", user, "session", id)
+	return nil
+}
+
+// remove drops a session; the caller holds mu.
+func (s *SessionService) remove(id string) {
+	sess, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	delete(s.sessions, id)
+	ids := slices.DeleteFunc(s.byUser[sess.User], func(other string) bool { return other == id })
+	if len(ids) == 0 {
+		delete(s.byUser, sess.User)
+		return
+	}
+	s.byUser[sess.User] = ids
+}
+
+// Len reports how many sessions are stored, expired ones included until swept.
+func (s *SessionService) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Ping reports whether the store can be locked before ctx is done.
+func (s *SessionService) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, s.mu.TryLock); err != nil {
+		return err
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// RunSweeper drops expired sessions every interval until ctx is done.
+func (s *SessionService) RunSweeper(ctx context.Context, interval time.Duration) {
+	schedule.Every(ctx, interval, s.sweep)
+}
+
+func (s *SessionService) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, sess := range s.sessions {
+		if now.After(sess.ExpiresAt) {
+			s.remove(id)
+		}
+	}
+}
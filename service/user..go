@@ -1,39 +1,90 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"dekamond-task/model"
+	"dekamond-task/package/health"
+	"dekamond-task/package/i18n"
+	"dekamond-task/package/logger"
+	"dekamond-task/package/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var tracer = otel.Tracer("dekamond-task/service")
+
+var (
+	ErrUserNotFound    = errors.New("User not found")
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	ErrPasskeyExists   = errors.New("this passkey is already registered")
 )
 
 type UserService struct {
+	log   *slog.Logger
 	mu    sync.RWMutex
-	users map[string]model.User // phone -> User
+	users map[string]model.User // phone or email -> User
+	// passkeys indexes credential IDs, which are unique across users
+	passkeys map[string]string // credential ID -> phone or email
 }
 
-func NewUserService() *UserService {
-	return &UserService{users: make(map[string]model.User)}
+func NewUserService(log *slog.Logger) *UserService {
+	return &UserService{log: log, users: make(map[string]model.User), passkeys: make(map[string]string)}
 }
 
-// RegisterIfNotExists adds the user if new, and returns the user.
-func (u *UserService) RegisterIfNotExists(phone string) model.User {
+// RegisterIfNotExists adds the user identified by a phone or email if new, and
+// returns the user.
+func (u *UserService) RegisterIfNotExists(ctx context.Context, identifier string) model.User {
+	ctx, span := tracer.Start(ctx, "UserService.RegisterIfNotExists")
+	defer span.End()
+
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	if usr, exists := u.users[phone]; exists {
+	if usr, exists := u.users[identifier]; exists {
+		metrics.Logins.WithLabelValues("login").Inc()
+		span.SetAttributes(attribute.Bool("user.created", false))
 		return usr
 	}
-	newUser := model.User{Phone: phone, RegisteredAt: time.Now()}
-	u.users[phone] = newUser
+	newUser := model.User{RegisteredAt: time.Now(), Locale: i18n.FromContext(ctx)}
+	if model.IsEmail(identifier) {
+		newUser.Email = identifier
+	} else {
+		newUser.Phone = identifier
+	}
+	u.users[identifier] = newUser
+	metrics.Logins.WithLabelValues("registration").Inc()
+	span.SetAttributes(attribute.Bool("user.created", true))
+	logger.FromContext(ctx, u.log).Info("user registered", "user", identifier)
 	return newUser
 }
 
-func (u *UserService) GetUser(phone string) (model.User, bool) {
+// Ping reports whether the store can be read before ctx is done.
+func (u *UserService) Ping(ctx context.Context) error {
+	if err := health.TryLock(ctx, u.mu.TryRLock); err != nil {
+		return err
+	}
+	u.mu.RUnlock()
+	return nil
+}
+
+// GetUser looks a user up by phone or email.
+func (u *UserService) GetUser(identifier string) (model.User, error) {
 	u.mu.RLock()
 	defer u.mu.RUnlock()
-	usr, exists := u.users[phone]
-	return usr, exists
+	usr, exists := u.users[identifier]
+	if !exists {
+		return model.User{}, ErrUserNotFound
+	}
+	return usr, nil
 }
 
 // ListUsers returns users filtered by search and paginated.
@@ -42,7 +93,7 @@ func (u *UserService) ListUsers(search string, page, size int) (result []model.U
 	defer u.mu.RUnlock()
 	// Collect all matching users
 	for _, usr := range u.users {
-		if search == "" || strings.Contains(usr.Phone, search) {
+		if search == "" || strings.Contains(usr.ID(), search) {
 			result = append(result, usr)
 		}
 	}
@@ -59,3 +110,84 @@ func (u *UserService) ListUsers(search string, page, size int) (result []model.U
 	}
 	return result[start:end], total
 }
+
+// PasskeyUserHandle returns the WebAuthn user handle of identifier, creating
+// it on first use. The handle is random so authenticators never store the
+// phone or email.
+func (u *UserService) PasskeyUserHandle(identifier string) ([]byte, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usr, exists := u.users[identifier]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	if usr.PasskeyID == nil {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return nil, fmt.Errorf("generate user handle: %w", err)
+		}
+		usr.PasskeyID = id
+		u.users[identifier] = usr
+	}
+	return usr.PasskeyID, nil
+}
+
+// AddPasskey stores a newly registered passkey for identifier.
+func (u *UserService) AddPasskey(identifier string, pk model.Passkey) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usr, exists := u.users[identifier]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if _, taken := u.passkeys[pk.ID]; taken {
+		return ErrPasskeyExists
+	}
+	// Copy so callers holding an earlier User never see the change
+	usr.Passkeys = append(slices.Clip(usr.Passkeys), pk)
+	u.users[identifier] = usr
+	u.passkeys[pk.ID] = identifier
+	return nil
+}
+
+// PasskeyOwner finds the user a credential ID was registered to.
+func (u *UserService) PasskeyOwner(credentialID string) (model.User, model.Passkey, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	usr := u.users[u.passkeys[credentialID]]
+	for _, pk := range usr.Passkeys {
+		if pk.ID == credentialID {
+			return usr, pk, nil
+		}
+	}
+	return model.User{}, model.Passkey{}, ErrPasskeyNotFound
+}
+
+// TouchPasskey records a sign-in with a passkey and its new signature counter.
+func (u *UserService) TouchPasskey(identifier, credentialID string, signCount uint32) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usr := u.users[identifier]
+	i := slices.IndexFunc(usr.Passkeys, func(pk model.Passkey) bool { return pk.ID == credentialID })
+	if i < 0 {
+		return
+	}
+	usr.Passkeys = slices.Clone(usr.Passkeys)
+	usr.Passkeys[i].SignCount = signCount
+	usr.Passkeys[i].LastUsedAt = time.Now()
+	u.users[identifier] = usr
+}
+
+// RemovePasskey deletes a passkey of identifier.
+func (u *UserService) RemovePasskey(identifier, credentialID string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usr, exists := u.users[identifier]
+	if !exists || u.passkeys[credentialID] != identifier {
+		return ErrPasskeyNotFound
+	}
+	usr.Passkeys = slices.DeleteFunc(slices.Clone(usr.Passkeys), func(pk model.Passkey) bool { return pk.ID == credentialID })
+	u.users[identifier] = usr
+	delete(u.passkeys, credentialID)
+	return nil
+}